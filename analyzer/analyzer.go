@@ -1,6 +1,7 @@
 package analyzer
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/importer"
@@ -9,17 +10,31 @@ import (
 	"go/types"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	"golang.org/x/tools/go/packages"
 
+	"github.com/Arneball/goasted/cache"
+	"github.com/Arneball/goasted/config"
 	"github.com/Arneball/goasted/rules"
 )
 
 // Analyzer analyzes Go source code for rule violations
 type Analyzer struct {
-	registry *rules.Registry
+	registry     *rules.Registry
+	config       *config.Resolver
+	reportUnused bool
+	builds       []BuildConfig
+	jobs         int
+	ruleTimeout  time.Duration
+	env          []string
+	filter       func(filename string) bool
+	cache        *cache.Cache
+	only         map[string]bool
+	declared     declaredRules
 }
 
 // New creates a new Analyzer with the given rule registry
@@ -29,101 +44,521 @@ func New(registry *rules.Registry) *Analyzer {
 	}
 }
 
-// Analyze analyzes the given path (file or directory) and returns violations
-func (a *Analyzer) Analyze(path string) ([]rules.Violation, error) {
-	info, err := os.Stat(path)
+// SetConfig makes the analyzer honour project configuration files: rules
+// can be disabled or configured and files can be included or excluded per
+// directory
+func (a *Analyzer) SetConfig(resolver *config.Resolver) {
+	a.config = resolver
+}
+
+// SetReportUnusedIgnores makes the analyzer report //goasted:ignore
+// directives that no longer suppress any violation
+func (a *Analyzer) SetReportUnusedIgnores(report bool) {
+	a.reportUnused = report
+}
+
+// SetBuildConfigs makes the analyzer load directories once per build
+// configuration. When there is more than one, violations are annotated with
+// the configurations they were found in.
+func (a *Analyzer) SetBuildConfigs(builds []BuildConfig) {
+	a.builds = builds
+}
+
+// SetConcurrency sets the number of files or package rules analyzed in
+// parallel. Zero or less means GOMAXPROCS.
+func (a *Analyzer) SetConcurrency(jobs int) {
+	a.jobs = jobs
+}
+
+// SetRuleTimeout sets the time budget of a single rule on a single file or
+// package. Zero means no budget.
+func (a *Analyzer) SetRuleTimeout(timeout time.Duration) {
+	a.ruleTimeout = timeout
+}
+
+// SetEnv adds variables, such as GOFLAGS=-mod=vendor, to the environment of
+// the go command that loads packages
+func (a *Analyzer) SetEnv(env []string) {
+	a.env = env
+}
+
+// SetFileFilter restricts the analysis to the files for which filter
+// returns true, on top of the include and exclude globs of the
+// configuration. A nil filter includes every file.
+func (a *Analyzer) SetFileFilter(filter func(filename string) bool) {
+	a.filter = filter
+}
+
+// SetRules restricts the analysis to the named rules, including rules
+// declared in configuration files. Nil runs every rule.
+func (a *Analyzer) SetRules(names []string) {
+	a.only = nil
+	if names != nil {
+		a.only = make(map[string]bool, len(names))
+		for _, name := range names {
+			a.only[name] = true
+		}
+	}
+
+	a.declared.mu.Lock()
+	defer a.declared.mu.Unlock()
+	a.declared.registries = nil
+}
+
+// workers returns the size of the worker pool
+func (a *Analyzer) workers() int {
+	if a.jobs > 0 {
+		return a.jobs
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// rulesFor returns the rules to run on filename, or nil if the file is
+// excluded by configuration. The returned config is nil when the analyzer
+// has no configuration.
+func (a *Analyzer) rulesFor(filename string) ([]rules.Checker, *config.Config, error) {
+	if a.filter != nil && !a.filter(filename) {
+		return nil, nil, nil
+	}
+	if a.config == nil {
+		registry, err := a.registryFor(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return registry.GetCheckers(), nil, nil
+	}
+
+	cfg, err := a.config.ForFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat path: %w", err)
+		return nil, nil, err
 	}
+	if !cfg.Included(filename) {
+		return nil, cfg, nil
+	}
+	registry, err := a.registryFor(cfg)
+	if err != nil {
+		return nil, cfg, err
+	}
+	enabled, err := cfg.EnabledRules(registry)
+	return enabled, cfg, err
+}
 
-	var violations []rules.Violation
+// knownRules returns whether ignore directives under cfg may name a rule: a
+// rule of the registry configuration is validated against or declared in
+// cfg, even if it isn't selected, or a rule the analyzer reports itself
+func (a *Analyzer) knownRules(cfg *config.Config) func(string) bool {
+	registry := a.registry
+	if a.config != nil {
+		registry = a.config.Registry()
+	}
+	return func(name string) bool {
+		if name == LoadErrorRule || name == InternalErrorRule {
+			return true
+		}
+		return registry.GetChecker(name) != nil || cfg.Declares(name)
+	}
+}
+
+// included reports whether filename is analyzed according to configuration
+func (a *Analyzer) included(filename string) bool {
+	if a.filter != nil && !a.filter(filename) {
+		return false
+	}
+	if a.config == nil {
+		return true
+	}
+	cfg, err := a.config.ForFile(filename)
+	return err == nil && cfg.Included(filename)
+}
+
+// withSeverity fills in the severity of a rule's violations: the configured
+// severity wins, then the one set by the rule, then the rule's default
+func withSeverity(rule rules.Checker, violations []rules.Violation, cfg *config.Config) []rules.Violation {
+	configured := rules.SeverityUnset
+	if cfg != nil {
+		configured = cfg.Severity(rule.Name())
+	}
+
+	for i := range violations {
+		switch {
+		case violations[i].Rule == InternalErrorRule:
+			// Failures of the rule itself keep their own severity
+		case configured != rules.SeverityUnset:
+			violations[i].Severity = configured
+		case violations[i].Severity == rules.SeverityUnset:
+			violations[i].Severity = rule.DefaultSeverity()
+		}
+	}
+	return violations
+}
+
+// Analyze analyzes the given path (file or directory) and returns violations.
+// A directory is analyzed recursively, including nested modules. Canceling
+// ctx stops package loading and the scheduling of further work.
+func (a *Analyzer) Analyze(ctx context.Context, path string) ([]rules.Violation, error) {
+	dir, patterns, err := Target(path)
+	if err != nil {
+		return nil, err
+	}
+	return a.AnalyzePatterns(ctx, dir, patterns)
+}
+
+// Target returns the directory and patterns that analyze path, a file or a
+// directory analyzed recursively, for AnalyzePatterns and Run
+func Target(path string) (string, []string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to stat path: %w", err)
+	}
 
 	if info.IsDir() {
-		violations, err = a.analyzeDirectory(path)
-	} else {
-		violations, err = a.analyzeFile(path)
+		return path, []string{"./..."}, nil
 	}
+	return ".", []string{path}, nil
+}
 
+// AnalyzePatterns analyzes the packages matching patterns, interpreted like
+// the go tool does relative to dir: ./... and other directory patterns,
+// import paths and .go files. Directory patterns ending in /... also cover
+// nested modules and go.work workspaces below them. All matches are merged
+// into one set of violations.
+func (a *Analyzer) AnalyzePatterns(ctx context.Context, dir string, patterns []string) ([]rules.Violation, error) {
+	result, err := a.Run(ctx, dir, patterns)
 	if err != nil {
 		return nil, err
 	}
+	return result.Violations, nil
+}
+
+// Run is like AnalyzePatterns, and also returns what was analyzed, the
+// packages that failed to load and statistics per rule
+func (a *Analyzer) Run(ctx context.Context, dir string, patterns []string) (*Result, error) {
+	plan, err := planLoads(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []rules.Violation
+	rs := newRunState()
+
+	for _, file := range plan.files {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("analysis canceled: %w", err)
+		}
+		fileViolations, err := a.analyzeFile(file, rs)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, fileViolations...)
+	}
+
+	for _, group := range plan.groups {
+		groupViolations, err := a.analyzeBuilds(ctx, group, rs)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, groupViolations...)
+	}
+
+	return a.finish(violations, rs), nil
+}
+
+// finish removes duplicate and suppressed violations and builds the Result
+// of a run
+func (a *Analyzer) finish(violations []rules.Violation, rs *runState) *Result {
+	// Test variants of a package and other build configurations report on
+	// the same files again. Sorting first makes the kept copy independent of
+	// goroutine scheduling.
+	sortViolations(violations)
+	violations = dedupe(violations)
+
+	// Drop violations silenced by //goasted:ignore directives
+	violations, _ = rs.sup.filter(violations, a.reportUnused)
+	sortViolations(violations)
+
+	return rs.result(violations, rs.sup.byRule)
+}
+
+// analyzeBuilds analyzes a load group once per build configuration
+func (a *Analyzer) analyzeBuilds(ctx context.Context, group *loadGroup, rs *runState) ([]rules.Violation, error) {
+	if len(a.builds) == 0 {
+		return a.analyzePackages(ctx, group, BuildConfig{}, rs)
+	}
 
+	var violations []rules.Violation
+	for _, build := range a.builds {
+		buildViolations, err := a.analyzePackages(ctx, group, build, rs)
+		if err != nil {
+			return nil, err
+		}
+		if len(a.builds) > 1 {
+			for i := range buildViolations {
+				buildViolations[i].Configurations = []string{build.String()}
+			}
+		}
+		violations = append(violations, buildViolations...)
+	}
 	return violations, nil
 }
 
-// analyzeDirectory recursively analyzes all Go files in a directory
-func (a *Analyzer) analyzeDirectory(dir string) ([]rules.Violation, error) {
+// analyzePackages loads the packages of a load group and analyzes them
+func (a *Analyzer) analyzePackages(ctx context.Context, group *loadGroup, build BuildConfig, rs *runState) ([]rules.Violation, error) {
 	var violations []rules.Violation
 
-	// Try to load packages with type information
+	// Only load the packages that aren't in the cache
+	var plan *cachePlan
+	patterns := group.patterns
+	if a.cache != nil {
+		if plan = a.lookupCache(ctx, group, build, rs); plan != nil {
+			violations = append(violations, plan.hits...)
+			if len(plan.analyze) == 0 {
+				return violations, nil
+			}
+			patterns = plan.patterns
+		}
+	}
+
+	// Load and parse the packages, and list their dependencies with their
+	// export data. They are type-checked below rather than by
+	// packages.Load, to time each package.
 	cfg := &packages.Config{
-		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
-		Dir:   dir,
-		Tests: true, // Include test files
+		Context:    ctx,
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedSyntax | packages.NeedImports | packages.NeedExportFile | packages.NeedTypesSizes | packages.NeedModule,
+		Dir:        group.dir,
+		Tests:      true, // Include test files
+		BuildFlags: build.buildFlags(),
+		Env:        build.env(a.env),
 	}
 
-	pkgs, err := packages.Load(cfg, "./...")
+	start := time.Now()
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err == nil && len(pkgs) == 0 {
+		// go list failures, such as an unsupported platform, are only
+		// reported without export data
+		listCfg := *cfg
+		listCfg.Mode &^= packages.NeedExportFile
+		_, err = packages.Load(&listCfg, patterns...)
+	}
+	rs.loaded(time.Since(start))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("analysis canceled: %w", ctxErr)
+	}
+	if err != nil && (!build.isDefault() || len(group.fallbackDirs) == 0) {
+		// The fallback can't honour tags or platforms, nor import paths
+		return nil, fmt.Errorf("failed to load packages for %s: %w", build, err)
+	}
 	if err != nil || len(pkgs) == 0 {
 		// Fallback to file-by-file analysis if package loading fails
-		return a.analyzeDirectoryFallback(dir)
+		for _, dir := range group.fallbackDirs {
+			if err != nil {
+				rs.loadError(dir, []string{err.Error()})
+			}
+			dirViolations, err := a.analyzeDirectoryFallback(dir, rs)
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, dirViolations...)
+		}
+		return violations, nil
 	}
 
-	// Analyze each file concurrently
-	violationsChan := make(chan []rules.Violation, 100)
-	var wg sync.WaitGroup
+	if err := checkTypes(ctx, pkgs, a.workers(), rs); err != nil {
+		return nil, fmt.Errorf("analysis canceled: %w", err)
+	}
+
+	loadedViolations, err := a.analyzeLoaded(ctx, pkgs, plan, rs)
+	if err != nil {
+		return nil, err
+	}
+	return append(violations, loadedViolations...), nil
+}
+
+// analyzeLoaded runs the rules on type-checked packages, except those the
+// cache plan doesn't analyze
+func (a *Analyzer) analyzeLoaded(ctx context.Context, pkgs []*packages.Package, plan *cachePlan, rs *runState) ([]rules.Violation, error) {
+	var violations []rules.Violation
+
+	graph := importGraph(pkgs)
+	prog := newSSAProgram(pkgs)
+
+	// Queue one task per file and per package rule, then run them on a
+	// bounded number of workers
+	var tasks []task
+	var configErr error
 
 	for _, pkg := range pkgs {
-		// Skip packages with errors (but continue analyzing others)
+		// The main package of a test binary is generated into the build
+		// cache
+		if strings.HasSuffix(pkg.ID, ".test") || !plan.analyzes(pkg) {
+			continue
+		}
+
+		// Type-check packages that failed to load as a whole, tolerating
+		// errors, and say why their results may be incomplete
+		if len(pkg.Errors) > 0 || pkg.IllTyped {
+			plan.skip(pkg)
+		}
 		if len(pkg.Errors) > 0 {
-			// Try individual files as fallback
-			for _, file := range pkg.GoFiles {
-				wg.Add(1)
-
-				go func() {
-					defer wg.Done()
-					if fileViolations, err := a.analyzeFile(file); err == nil {
-						violationsChan <- fileViolations
-					}
-				}()
+			if v, ok := loadErrorViolation(pkg); ok && a.included(v.File) {
+				violations = append(violations, v)
 			}
-			continue
+			errs := make([]string, len(pkg.Errors))
+			for i, e := range pkg.Errors {
+				errs[i] = e.Error()
+			}
+			rs.loadError(pkg.PkgPath, errs)
+			pkg = recheckPackage(pkg)
 		}
 
 		// Analyze each file in the package with full type information
-		getRules := a.registry.GetRules()
+		var pkgTasks []task
 		for i, file := range pkg.Syntax {
-			wg.Add(len(getRules))
-
-			ctx := &rules.Context{
-				FileSet:  pkg.Fset,
-				File:     file,
-				Filename: pkg.GoFiles[i],
-				TypeInfo: pkg.TypesInfo,
+			getRules, fileConfig, err := a.rulesFor(pkg.GoFiles[i])
+			if err != nil {
+				if configErr == nil {
+					configErr = err
+				}
+				continue
+			}
+			if len(getRules) == 0 {
+				continue
 			}
 
-			for _, rule := range getRules {
-				go func() {
-					defer wg.Done()
-					violationsChan <- rule.Check(ctx)
-				}()
+			fileCtx := &rules.Context{
+				FileSet:      pkg.Fset,
+				File:         file,
+				Filename:     pkg.GoFiles[i],
+				TypeInfo:     pkg.TypesInfo,
+				Package:      pkg.Types,
+				PackageFiles: pkg.GoFiles,
 			}
+			rs.sup.record(fileCtx, getRules, a.knownRules(fileConfig))
+			rs.analyzed(fileCtx.Filename)
+
+			pkgTasks = append(pkgTasks, func() []rules.Violation {
+				var fileViolations []rules.Violation
+				for _, rule := range getRules {
+					if fileRule, ok := rule.(rules.Rule); ok {
+						ruleViolations := a.runRule(rs, rule, fileCtx.Filename, fileCtx.Filename, func() []rules.Violation {
+							return fileRule.Check(fileCtx)
+						})
+						fileViolations = append(fileViolations, withSeverity(rule, ruleViolations, fileConfig)...)
+					}
+				}
+				return fileViolations
+			})
+		}
+
+		// Run package rules once per package
+		ruleTasks, err := a.checkPackage(pkg, graph, prog, rs)
+		if err != nil && configErr == nil {
+			configErr = err
 		}
+		tasks = append(tasks, plan.collect(pkg, append(pkgTasks, ruleTasks...))...)
 	}
 
-	go func() {
-		wg.Wait()
-		close(violationsChan)
-	}()
+	if configErr != nil {
+		return nil, configErr
+	}
 
-	for v := range violationsChan {
-		violations = append(violations, v...)
+	taskViolations, err := runTasks(ctx, a.workers(), tasks)
+	if err != nil {
+		return nil, fmt.Errorf("analysis canceled: %w", err)
 	}
+	plan.store(rs)
+	return append(violations, taskViolations...), nil
+}
 
-	return violations, nil
+// checkPackage returns tasks running the package and SSA rules enabled for
+// pkg. Files excluded by configuration are left out of the package context,
+// and SSA rule violations in them are dropped.
+func (a *Analyzer) checkPackage(pkg *packages.Package, graph map[string][]string, prog *ssaProgram, rs *runState) ([]task, error) {
+	pkgCtx := &rules.PackageContext{
+		FileSet:   pkg.Fset,
+		Package:   pkg.Types,
+		TypesInfo: pkg.TypesInfo,
+		Imports:   graph,
+	}
+	for i, file := range pkg.Syntax {
+		if !a.included(pkg.GoFiles[i]) {
+			continue
+		}
+		pkgCtx.Files = append(pkgCtx.Files, file)
+		pkgCtx.Filenames = append(pkgCtx.Filenames, pkg.GoFiles[i])
+	}
+	if len(pkgCtx.Files) == 0 {
+		return nil, nil
+	}
+
+	// The rules and their configuration come from the first analyzed file
+	pkgRules, pkgConfig, err := a.rulesFor(pkgCtx.Filenames[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []task
+	for _, rule := range pkgRules {
+		pkgRule, isPkgRule := rule.(rules.PackageRule)
+		ssaRule, isSSARule := rule.(rules.SSARule)
+		if !isPkgRule && !isSSARule {
+			continue
+		}
+		for i, file := range pkgCtx.Files {
+			rs.sup.record(&rules.Context{FileSet: pkg.Fset, File: file, Filename: pkgCtx.Filenames[i], TypeInfo: pkg.TypesInfo}, []rules.Checker{rule}, a.knownRules(pkgConfig))
+			rs.analyzed(pkgCtx.Filenames[i])
+		}
+
+		if isPkgRule {
+			tasks = append(tasks, func() []rules.Violation {
+				violations := a.runRule(rs, rule, pkgCtx.Filenames[0], "package "+pkg.PkgPath, func() []rules.Violation {
+					return pkgRule.CheckPackage(pkgCtx)
+				})
+				return withSeverity(rule, violations, pkgConfig)
+			})
+		}
+
+		if isSSARule {
+			tasks = append(tasks, func() []rules.Violation {
+				violations := a.runRule(rs, rule, pkgCtx.Filenames[0], "package "+pkg.PkgPath, func() []rules.Violation {
+					ssaCtx := prog.context(pkg)
+					if ssaCtx == nil {
+						return nil
+					}
+					return ssaRule.CheckSSA(ssaCtx)
+				})
+				var included []rules.Violation
+				for _, v := range violations {
+					if a.included(v.File) {
+						included = append(included, v)
+					}
+				}
+				return withSeverity(rule, included, pkgConfig)
+			})
+		}
+	}
+	return tasks, nil
+}
+
+// importGraph maps the path of every loaded package, including
+// dependencies, to the paths it imports directly
+func importGraph(pkgs []*packages.Package) map[string][]string {
+	graph := make(map[string][]string)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if _, ok := graph[pkg.PkgPath]; ok {
+			return
+		}
+		imports := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			imports = append(imports, path)
+		}
+		sort.Strings(imports)
+		graph[pkg.PkgPath] = imports
+	})
+	return graph
 }
 
 // analyzeDirectoryFallback is the fallback for when package loading fails
-func (a *Analyzer) analyzeDirectoryFallback(dir string) ([]rules.Violation, error) {
+func (a *Analyzer) analyzeDirectoryFallback(dir string, rs *runState) ([]rules.Violation, error) {
 	var violations []rules.Violation
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -145,7 +580,7 @@ func (a *Analyzer) analyzeDirectoryFallback(dir string) ([]rules.Violation, erro
 			return nil
 		}
 
-		fileViolations, err := a.analyzeFile(path)
+		fileViolations, err := a.analyzeFile(path, rs)
 		if err != nil {
 			return fmt.Errorf("failed to analyze %s: %w", path, err)
 		}
@@ -162,7 +597,15 @@ func (a *Analyzer) analyzeDirectoryFallback(dir string) ([]rules.Violation, erro
 }
 
 // analyzeFile analyzes a single Go file
-func (a *Analyzer) analyzeFile(filename string) ([]rules.Violation, error) {
+func (a *Analyzer) analyzeFile(filename string, rs *runState) ([]rules.Violation, error) {
+	fileRules, fileConfig, err := a.rulesFor(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileRules) == 0 {
+		return nil, nil
+	}
+
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
 	if err != nil {
@@ -178,12 +621,20 @@ func (a *Analyzer) analyzeFile(filename string) ([]rules.Violation, error) {
 		Filename: filename,
 		TypeInfo: typeInfo,
 	}
+	rs.sup.record(ctx, fileRules, a.knownRules(fileConfig))
+	rs.analyzed(filename)
 
 	var violations []rules.Violation
 
 	// Apply all rules to the file
-	for _, rule := range a.registry.GetRules() {
-		ruleViolations := rule.Check(ctx)
+	for _, rule := range fileRules {
+		fileRule, ok := rule.(rules.Rule)
+		if !ok {
+			continue
+		}
+		ruleViolations := withSeverity(rule, a.runRule(rs, rule, filename, filename, func() []rules.Violation {
+			return fileRule.Check(ctx)
+		}), fileConfig)
 		violations = append(violations, ruleViolations...)
 	}
 
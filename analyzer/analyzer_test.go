@@ -0,0 +1,242 @@
+package analyzer
+
+import (
+	"context"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// crossFileRule reports uses of a named type declared in another file of
+// the same package
+type crossFileRule struct {
+	mu    sync.Mutex
+	calls int
+	ctx   *rules.PackageContext
+}
+
+func (r *crossFileRule) Name() string                    { return "cross-file" }
+func (r *crossFileRule) Description() string             { return "test rule" }
+func (r *crossFileRule) DefaultSeverity() rules.Severity { return rules.SeverityWarning }
+
+func (r *crossFileRule) CheckPackage(ctx *rules.PackageContext) []rules.Violation {
+	r.mu.Lock()
+	r.calls++
+	r.ctx = ctx
+	r.mu.Unlock()
+
+	var violations []rules.Violation
+	for i, file := range ctx.Files {
+		for ident, obj := range ctx.TypesInfo.Uses {
+			if ctx.FileSet.File(ident.Pos()) != ctx.FileSet.File(file.Pos()) {
+				continue
+			}
+			tn, ok := obj.(*types.TypeName)
+			if !ok || tn.Pkg() != ctx.Package {
+				continue
+			}
+			if ctx.FileSet.File(tn.Pos()) == ctx.FileSet.File(file.Pos()) {
+				continue
+			}
+			pos := ctx.FileSet.Position(ident.Pos())
+			violations = append(violations, rules.Violation{
+				File:    ctx.Filenames[i],
+				Line:    pos.Line,
+				Column:  pos.Column,
+				Rule:    r.Name(),
+				Message: tn.Name() + " is declared in another file",
+			})
+		}
+	}
+	return violations
+}
+
+func TestAnalyze_DispatchesPackageRules(t *testing.T) {
+	rule := &crossFileRule{}
+	registry := rules.NewRegistry()
+	registry.Register(rule)
+
+	violations, err := New(registry).Analyze(context.Background(), filepath.Join("testdata", "multifile"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if rule.calls != 1 {
+		t.Errorf("Expected package rule to run once, got %d", rule.calls)
+	}
+	if rule.ctx == nil || len(rule.ctx.Files) != 2 || len(rule.ctx.Filenames) != 2 {
+		t.Fatalf("Expected package context with 2 files, got %+v", rule.ctx)
+	}
+	if _, ok := rule.ctx.Imports["database/sql"]; !ok {
+		t.Errorf("Expected import graph to contain database/sql")
+	}
+	if imports := rule.ctx.Imports["multifile"]; len(imports) != 1 || imports[0] != "database/sql" {
+		t.Errorf("Expected multifile to import database/sql, got %v", imports)
+	}
+
+	// service.go uses Repository, which is declared in types.go
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, got %d: %v", len(violations), violations)
+	}
+	if filepath.Base(violations[0].File) != "service.go" || violations[0].Severity != rules.SeverityWarning {
+		t.Errorf("Unexpected violation: %+v", violations[0])
+	}
+}
+
+func TestAnalyze_PackageRulesSkipExcludedFirstFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/p\n\ngo 1.22\n",
+		"a_gen.go":   "package p\n\nvar generated Repository\n",
+		"service.go": "package p\n\nfunc Use(r Repository) {}\n",
+		"types.go":   "package p\n\ntype Repository struct{}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rule := &crossFileRule{}
+	registry := rules.NewRegistry()
+	registry.Register(rule)
+	resolver := config.NewResolver(registry)
+	if err := resolver.SetOverride(dir, &config.File{Exclude: []string{"*_gen.go"}}); err != nil {
+		t.Fatalf("SetOverride failed: %v", err)
+	}
+	a := New(registry)
+	a.SetConfig(resolver)
+
+	// a_gen.go sorts first, but being excluded doesn't turn package rules
+	// off for the rest of the package
+	violations, err := a.Analyze(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if rule.calls != 1 || len(rule.ctx.Files) != 2 {
+		t.Fatalf("Expected package rule to run once on 2 files, got %d runs with %+v", rule.calls, rule.ctx)
+	}
+	if len(violations) != 1 || filepath.Base(violations[0].File) != "service.go" {
+		t.Errorf("Expected 1 violation in service.go, got %v", violations)
+	}
+}
+
+func TestAnalyze_FileRulesSeeCrossFileTypes(t *testing.T) {
+	registry := rules.NewRegistry()
+	registry.Register(rules.NewSqlContextRule())
+
+	violations, err := New(registry).Analyze(context.Background(), filepath.Join("testdata", "multifile"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	// s.repo.Connection.Query and .Exec in service.go
+	if len(violations) != 2 {
+		t.Errorf("Expected 2 violations, got %d: %v", len(violations), violations)
+	}
+}
+
+// queryCallRule reports static calls to (*sql.DB).Query found in SSA
+type queryCallRule struct {
+	mu        sync.Mutex
+	functions []string
+}
+
+func (r *queryCallRule) Name() string                    { return "ssa-query" }
+func (r *queryCallRule) Description() string             { return "test rule" }
+func (r *queryCallRule) DefaultSeverity() rules.Severity { return rules.SeverityInfo }
+
+func (r *queryCallRule) CheckSSA(ctx *rules.SSAContext) []rules.Violation {
+	var violations []rules.Violation
+	for _, fn := range ctx.Functions {
+		r.mu.Lock()
+		r.functions = append(r.functions, fn.Name())
+		r.mu.Unlock()
+
+		for _, block := range fn.Blocks {
+			for _, instr := range block.Instrs {
+				call, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				callee := call.Common().StaticCallee()
+				if callee == nil || callee.String() != "(*database/sql.DB).Query" {
+					continue
+				}
+				pos := ctx.FileSet.Position(call.Pos())
+				violations = append(violations, rules.Violation{
+					File:    pos.Filename,
+					Line:    pos.Line,
+					Column:  pos.Column,
+					Rule:    r.Name(),
+					Message: "Query call",
+				})
+			}
+		}
+	}
+	return violations
+}
+
+func TestAnalyze_DispatchesSSARules(t *testing.T) {
+	rule := &queryCallRule{}
+	registry := rules.NewRegistry()
+	registry.Register(rule)
+
+	violations, err := New(registry).Analyze(context.Background(), filepath.Join("testdata", "multifile"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, got %d: %v", len(violations), violations)
+	}
+	if filepath.Base(violations[0].File) != "service.go" || violations[0].Severity != rules.SeverityInfo {
+		t.Errorf("Unexpected violation: %+v", violations[0])
+	}
+
+	// Methods are part of the checked functions
+	sort.Strings(rule.functions)
+	if len(rule.functions) != 2 || rule.functions[0] != "GetUser" || rule.functions[1] != "UpdateUser" {
+		t.Errorf("Expected GetUser and UpdateUser, got %v", rule.functions)
+	}
+}
+
+func TestAnalyze_DegradedPackageKeepsCrossFileTypes(t *testing.T) {
+	registry := rules.NewRegistry()
+	registry.Register(rules.NewSqlContextRule())
+
+	violations, err := New(registry).Analyze(context.Background(), filepath.Join("testdata", "degraded"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var sqlViolations, loadErrors int
+	for _, v := range violations {
+		switch v.Rule {
+		case LoadErrorRule:
+			loadErrors++
+			if filepath.Base(v.File) != "broken.go" || v.Severity != rules.SeverityWarning {
+				t.Errorf("Unexpected load error: %+v", v)
+			}
+		case "sql-context-required":
+			sqlViolations++
+		}
+	}
+
+	// broken.go imports a missing module, but service.go still sees the
+	// *sql.DB field declared in types.go
+	if sqlViolations != 2 {
+		t.Errorf("Expected 2 sql violations, got %d: %v", sqlViolations, violations)
+	}
+	if loadErrors != 1 {
+		t.Errorf("Expected 1 load error, got %d: %v", loadErrors, violations)
+	}
+}
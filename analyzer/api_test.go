@@ -0,0 +1,282 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arneball/goasted/analyzer"
+	"github.com/Arneball/goasted/cache"
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// The library API is used by tools embedding goasted. These assignments
+// break the build if a signature changes incompatibly.
+var (
+	_ func(context.Context, analyzer.Options) (*analyzer.Result, error)                     = analyzer.Analyze
+	_ func(*analyzer.Analyzer, context.Context, string, []string) (*analyzer.Result, error) = (*analyzer.Analyzer).Run
+	_ func(*analyzer.Analyzer, context.Context, string) ([]rules.Violation, error)          = (*analyzer.Analyzer).Analyze
+	_ func(string) (string, []string, error)                                                = analyzer.Target
+
+	_ = analyzer.Options{
+		Dir:                 ".",
+		Paths:               []string{},
+		Patterns:            []string{},
+		Env:                 []string{},
+		Tags:                []string{},
+		Platforms:           []string{},
+		Registry:            rules.DefaultRegistry(),
+		Rules:               []string{},
+		IgnoreConfigFiles:   false,
+		Config:              &config.File{},
+		FileFilter:          func(string) bool { return true },
+		Jobs:                1,
+		RuleTimeout:         time.Second,
+		ReportUnusedIgnores: false,
+		Cache:               (*cache.Cache)(nil),
+	}
+	_ = analyzer.Result{
+		Violations: []rules.Violation{},
+		LoadErrors: []analyzer.PackageError{{Package: "", Errors: []string{}}},
+		Files:      0,
+		Suppressed: 0,
+		Rules:      map[string]analyzer.RuleStats{"": {Runs: 0, Duration: 0, Max: 0, Violations: 0, Suppressed: 0}},
+		Load:       0,
+		TypeCheck:  map[string]time.Duration{},
+
+		CacheHits:   0,
+		CacheMisses: 0,
+	}
+)
+
+// writeModule writes a module with SQL calls lacking a context, one of them
+// suppressed, and a package that doesn't type-check
+func writeModule(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/api\n\ngo 1.22\n",
+		"store/store.go": `package store
+
+import "database/sql"
+
+func Load(db *sql.DB) {
+	db.Query("SELECT 1")
+	//goasted:ignore sql-context-required -- legacy
+	db.Exec("DELETE FROM t")
+}
+`,
+		"store/store_test.go": `package store
+
+import "testing"
+
+func TestLoad(t *testing.T) {}
+`,
+		"broken/broken.go": `package broken
+
+import "database/sql"
+
+func Ping(db *sql.DB) error {
+	undefined()
+	return db.Ping()
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestAnalyze_Result(t *testing.T) {
+	dir := writeModule(t)
+
+	result, err := analyzer.Analyze(context.Background(), analyzer.Options{Dir: dir, Jobs: 2})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var sqlViolations int
+	for _, v := range result.Violations {
+		if v.Rule == "sql-context-required" {
+			sqlViolations++
+		}
+	}
+	if sqlViolations != 2 {
+		t.Errorf("Expected 2 sql-context-required violations, got %d: %v", sqlViolations, result.Violations)
+	}
+	if result.Files != 3 {
+		t.Errorf("Expected 3 analyzed files, got %d", result.Files)
+	}
+	if result.Suppressed != 1 {
+		t.Errorf("Expected 1 suppressed violation, got %d", result.Suppressed)
+	}
+
+	stats, ok := result.Rules["sql-context-required"]
+	if !ok {
+		t.Fatalf("Expected statistics for sql-context-required, got %v", result.Rules)
+	}
+	if stats.Runs < 3 || stats.Violations != 2 || stats.Suppressed != 1 {
+		t.Errorf("Unexpected sql-context-required statistics: %+v", stats)
+	}
+	if stats.Max <= 0 || stats.Max > stats.Duration {
+		t.Errorf("Expected the slowest run to be within the total time, got %+v", stats)
+	}
+
+	if result.Load <= 0 {
+		t.Errorf("Expected the load time to be recorded, got %v", result.Load)
+	}
+	for _, pkg := range []string{"example.com/api/store", "example.com/api/broken"} {
+		if _, ok := result.TypeCheck[pkg]; !ok {
+			t.Errorf("Expected the type-check time of %s to be recorded, got %v", pkg, result.TypeCheck)
+		}
+	}
+	if _, ok := result.TypeCheck["database/sql"]; ok {
+		t.Errorf("Expected dependencies to come from export data, got %v", result.TypeCheck)
+	}
+
+	if len(result.LoadErrors) != 1 || result.LoadErrors[0].Package != "example.com/api/broken" {
+		t.Fatalf("Expected a load error for example.com/api/broken, got %+v", result.LoadErrors)
+	}
+	if !strings.Contains(strings.Join(result.LoadErrors[0].Errors, "\n"), "undefined") {
+		t.Errorf("Expected the type error to be reported, got %v", result.LoadErrors[0].Errors)
+	}
+}
+
+func TestAnalyze_Filters(t *testing.T) {
+	dir := writeModule(t)
+
+	result, err := analyzer.Analyze(context.Background(), analyzer.Options{
+		Dir:        dir,
+		Paths:      []string{"store"},
+		Rules:      []string{"sql-context-required"},
+		FileFilter: func(filename string) bool { return !strings.HasSuffix(filename, "_test.go") },
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if result.Files != 1 {
+		t.Errorf("Expected only store.go to be analyzed, got %d files", result.Files)
+	}
+	if len(result.Rules) != 1 {
+		t.Errorf("Expected only sql-context-required to run, got %v", result.Rules)
+	}
+	if len(result.LoadErrors) != 0 {
+		t.Errorf("Expected broken to be skipped, got %+v", result.LoadErrors)
+	}
+}
+
+func TestAnalyze_ConfigOverride(t *testing.T) {
+	dir := writeModule(t)
+	if err := os.WriteFile(filepath.Join(dir, ".goasted.yaml"), []byte("exclude: [broken]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	disabled := false
+
+	result, err := analyzer.Analyze(context.Background(), analyzer.Options{
+		Dir:      dir,
+		Patterns: []string{"./store"},
+		Config: &config.File{
+			Rules: map[string]config.RuleConfig{
+				"sql-context-required": {Severity: "warning"},
+				"testify-usage":        {Enabled: &disabled},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(result.Violations) == 0 {
+		t.Fatalf("Expected violations")
+	}
+	for _, v := range result.Violations {
+		if v.Severity != rules.SeverityWarning {
+			t.Errorf("Expected the override to set severity warning, got %s", v.Severity)
+		}
+	}
+	if _, ok := result.Rules["testify-usage"]; ok {
+		t.Errorf("Expected testify-usage to be disabled by the override")
+	}
+}
+
+func TestAnalyze_IgnoreConfigFiles(t *testing.T) {
+	dir := writeModule(t)
+	if err := os.WriteFile(filepath.Join(dir, ".goasted.yaml"), []byte("rules:\n  sql-context-required:\n    enabled: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ignore := range []bool{false, true} {
+		result, err := analyzer.Analyze(context.Background(), analyzer.Options{
+			Dir:               dir,
+			Patterns:          []string{"./store"},
+			IgnoreConfigFiles: ignore,
+		})
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+		if _, ran := result.Rules["sql-context-required"]; ran != ignore {
+			t.Errorf("IgnoreConfigFiles=%v: expected sql-context-required to run %v, got %v", ignore, ignore, ran)
+		}
+	}
+}
+
+func TestAnalyze_DeclaredRules(t *testing.T) {
+	dir := writeModule(t)
+	cfg := `patterns:
+  raw-query:
+    pattern: $db.Query($*args)
+    message: use $db.QueryContext
+`
+	if err := os.WriteFile(filepath.Join(dir, "store", ".goasted.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rules declared in configuration run without a registry of their own
+	result, err := analyzer.Analyze(context.Background(), analyzer.Options{
+		Dir:   filepath.Join(dir, "store"),
+		Rules: []string{"raw-query"},
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(result.Violations) != 1 || result.Violations[0].Rule != "raw-query" || result.Violations[0].Message != "use db.QueryContext" {
+		t.Errorf("Expected 1 raw-query violation, got %v", result.Violations)
+	}
+	if len(result.Rules) != 1 {
+		t.Errorf("Expected only raw-query to run, got %v", result.Rules)
+	}
+}
+
+func TestAnalyze_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts analyzer.Options
+		want string
+	}{
+		{"unknown rule", analyzer.Options{Rules: []string{"no-such-rule"}}, `unknown rule "no-such-rule"`},
+		{"bad platform", analyzer.Options{Platforms: []string{"linux"}}, "invalid platform"},
+		{"missing path", analyzer.Options{Paths: []string{"does-not-exist"}}, "failed to stat path"},
+		{"invalid override", analyzer.Options{Config: &config.File{Rules: map[string]config.RuleConfig{"nope": {}}}}, `unknown rule "nope"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := analyzer.Analyze(context.Background(), tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// generateTree writes a module with the given number of packages and files
+// per package to a temporary directory. Every file has a context-less query
+// and a testify-free test file, so rules have work to do.
+func generateTree(b *testing.B, packages, files int) string {
+	b.Helper()
+
+	dir := b.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module bench\n\ngo 1.25\n"), 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	for p := 0; p < packages; p++ {
+		pkgDir := filepath.Join(dir, fmt.Sprintf("pkg%d", p))
+		if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		for f := 0; f < files; f++ {
+			src := fmt.Sprintf(`package pkg%d
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Store%d wraps a database connection
+type Store%d struct {
+	db *sql.DB
+}
+
+// Count%d counts rows without a context
+func (s *Store%d) Count%d() (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM t%d").Scan(&n)
+	return n, err
+}
+
+// CountContext%d counts rows with a context
+func (s *Store%d) CountContext%d(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t%d").Scan(&n)
+	return n, err
+}
+`, p, f, f, f, f, f, f, f, f, f, f)
+			if err := os.WriteFile(filepath.Join(pkgDir, fmt.Sprintf("file%d.go", f)), []byte(src), 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		test := fmt.Sprintf("package pkg%d\n\nimport \"testing\"\n\nfunc TestNothing(t *testing.T) {}\n", p)
+		if err := os.WriteFile(filepath.Join(pkgDir, "pkg_test.go"), []byte(test), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	sizes := []struct {
+		packages, files int
+	}{
+		{10, 10},
+		{50, 20},
+	}
+
+	jobCounts := []int{1}
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		jobCounts = append(jobCounts, procs)
+	}
+
+	for _, size := range sizes {
+		dir := generateTree(b, size.packages, size.files)
+		for _, jobs := range jobCounts {
+			name := fmt.Sprintf("packages=%d/files=%d/j=%d", size.packages, size.files, jobs)
+			b.Run(name, func(b *testing.B) {
+				a := New(rules.DefaultRegistry())
+				a.SetConcurrency(jobs)
+
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					violations, err := a.Analyze(context.Background(), dir)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(violations) != size.packages*size.files {
+						b.Fatalf("Expected %d violations, got %d", size.packages*size.files, len(violations))
+					}
+				}
+				b.ReportMetric(float64(size.packages*(size.files+1)*b.N)/b.Elapsed().Seconds(), "files/s")
+			})
+		}
+	}
+}
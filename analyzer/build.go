@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BuildConfig is a build configuration packages are loaded in. The zero
+// value is the host platform without extra tags.
+type BuildConfig struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// String names the configuration, e.g. "linux/amd64 tags=integration"
+func (c BuildConfig) String() string {
+	var parts []string
+	if c.GOOS != "" || c.GOARCH != "" {
+		parts = append(parts, c.GOOS+"/"+c.GOARCH)
+	}
+	if len(c.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(c.Tags, ","))
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, " ")
+}
+
+// isDefault reports whether c is the host configuration
+func (c BuildConfig) isDefault() bool {
+	return c.GOOS == "" && c.GOARCH == "" && len(c.Tags) == 0
+}
+
+// buildFlags returns the go build flags selecting c's tags
+func (c BuildConfig) buildFlags() []string {
+	if len(c.Tags) == 0 {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(c.Tags, ",")}
+}
+
+// env returns the environment selecting c's platform on top of the current
+// environment and extra, or nil if that's just the current environment
+func (c BuildConfig) env(extra []string) []string {
+	if c.GOOS == "" && c.GOARCH == "" && len(extra) == 0 {
+		return nil
+	}
+	// Later entries override inherited ones
+	env := append(os.Environ(), extra...)
+	if c.GOOS != "" || c.GOARCH != "" {
+		env = append(env, "GOOS="+c.GOOS, "GOARCH="+c.GOARCH)
+	}
+	return env
+}
+
+// BuildMatrix returns the configurations for a comma-separated list of
+// build tags and a comma-separated list of os/arch platforms. The tags apply
+// to every platform. Without platforms there is a single configuration for
+// the host platform.
+func BuildMatrix(tags, platforms string) ([]BuildConfig, error) {
+	tagList := splitList(tags)
+
+	platformList := splitList(platforms)
+	if len(platformList) == 0 {
+		return []BuildConfig{{Tags: tagList}}, nil
+	}
+
+	configs := make([]BuildConfig, 0, len(platformList))
+	seen := make(map[string]bool)
+	for _, platform := range platformList {
+		goos, goarch, ok := strings.Cut(platform, "/")
+		if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			return nil, fmt.Errorf("invalid platform %q: expected os/arch, e.g. linux/amd64", platform)
+		}
+		if seen[platform] {
+			continue
+		}
+		seen[platform] = true
+		configs = append(configs, BuildConfig{GOOS: goos, GOARCH: goarch, Tags: tagList})
+	}
+	return configs, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func TestBuildMatrix(t *testing.T) {
+	configs, err := BuildMatrix("integration, e2e", "linux/amd64,windows/arm64,linux/amd64")
+	if err != nil {
+		t.Fatalf("BuildMatrix failed: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("Expected 2 configurations, got %d: %v", len(configs), configs)
+	}
+	if got := configs[1].String(); got != "windows/arm64 tags=integration,e2e" {
+		t.Errorf("Expected windows/arm64 tags=integration,e2e, got %s", got)
+	}
+
+	configs, err = BuildMatrix("", "")
+	if err != nil {
+		t.Fatalf("BuildMatrix failed: %v", err)
+	}
+	if len(configs) != 1 || !configs[0].isDefault() {
+		t.Errorf("Expected the default configuration, got %v", configs)
+	}
+
+	if _, err := BuildMatrix("", "linux"); err == nil {
+		t.Error("Expected an error for a platform without an architecture")
+	}
+}
+
+// analyzePlatforms runs the sql rule on testdata/platforms in the given
+// configurations and returns the violations by file name
+func analyzePlatforms(t *testing.T, tags, platforms string) map[string]rules.Violation {
+	t.Helper()
+
+	builds, err := BuildMatrix(tags, platforms)
+	if err != nil {
+		t.Fatalf("BuildMatrix failed: %v", err)
+	}
+
+	registry := rules.NewRegistry()
+	registry.Register(rules.NewSqlContextRule())
+	a := New(registry)
+	a.SetBuildConfigs(builds)
+
+	violations, err := a.Analyze(context.Background(), filepath.Join("testdata", "platforms"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	byFile := make(map[string]rules.Violation)
+	for _, v := range violations {
+		if _, ok := byFile[filepath.Base(v.File)]; ok {
+			t.Errorf("Duplicate violation in %s: %v", v.File, violations)
+		}
+		byFile[filepath.Base(v.File)] = v
+	}
+	return byFile
+}
+
+func TestAnalyze_PlatformMatrix(t *testing.T) {
+	byFile := analyzePlatforms(t, "", "linux/amd64,windows/amd64")
+
+	if len(byFile) != 3 {
+		t.Fatalf("Expected violations in 3 files, got %v", byFile)
+	}
+	expected := map[string]string{
+		"common.go":  "linux/amd64,windows/amd64",
+		"linux.go":   "linux/amd64",
+		"windows.go": "windows/amd64",
+	}
+	for file, configs := range expected {
+		if got := strings.Join(byFile[file].Configurations, ","); got != configs {
+			t.Errorf("Expected %s in %s, got %s", file, configs, got)
+		}
+	}
+}
+
+func TestAnalyze_BuildTags(t *testing.T) {
+	if _, ok := analyzePlatforms(t, "", "linux/amd64")["integration.go"]; ok {
+		t.Error("Expected integration.go to be skipped without the integration tag")
+	}
+
+	byFile := analyzePlatforms(t, "integration", "linux/amd64")
+	v, ok := byFile["integration.go"]
+	if !ok {
+		t.Fatalf("Expected a violation in integration.go, got %v", byFile)
+	}
+	if len(v.Configurations) != 0 {
+		t.Errorf("Expected no annotation for a single configuration, got %v", v.Configurations)
+	}
+}
+
+func TestAnalyze_UnknownPlatform(t *testing.T) {
+	builds, err := BuildMatrix("", "plan10/amd64")
+	if err != nil {
+		t.Fatalf("BuildMatrix failed: %v", err)
+	}
+
+	a := New(rules.DefaultRegistry())
+	a.SetBuildConfigs(builds)
+	if _, err := a.Analyze(context.Background(), filepath.Join("testdata", "platforms")); err == nil {
+		t.Error("Expected an error for an unsupported platform")
+	}
+}
@@ -0,0 +1,416 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/Arneball/goasted/cache"
+	"github.com/Arneball/goasted/rules"
+)
+
+// cacheFormat changes whenever what is cached, or how keys are computed,
+// changes
+const cacheFormat = "goasted-results-1"
+
+// SetCache makes the analyzer reuse the violations of packages whose files,
+// dependencies, rules and configuration haven't changed since they were
+// stored in c, and store the violations of the packages it analyzes. Nil
+// disables caching.
+//
+// Package rules must only depend on the package and its dependencies for
+// cached results to be correct.
+func (a *Analyzer) SetCache(c *cache.Cache) {
+	a.cache = c
+}
+
+// cacheEntry is what the cache holds for a package
+type cacheEntry struct {
+	Violations []rules.Violation
+	Files      []cachedFile
+}
+
+// cachedFile is the suppression state of a file the rules ran on
+type cachedFile struct {
+	Filename    string
+	Directives  []cachedDirective
+	Invalid     []rules.Violation
+	ActiveRules []string
+}
+
+// cachedDirective is a parsed suppression directive
+type cachedDirective struct {
+	Pos       token.Position
+	Rules     []string
+	FileLevel bool
+}
+
+// toolID identifies the running goasted binary, and with it the built-in
+// rules. It is empty, disabling the cache, if the binary can't be read.
+var toolID = sync.OnceValue(func() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	hash, err := cache.HashFile(exe)
+	if err != nil {
+		return ""
+	}
+	return hash
+})
+
+// cachePlan is the outcome of looking up the packages of a load group in
+// the cache, and collects the violations of the packages analyzed instead
+type cachePlan struct {
+	cache *cache.Cache
+
+	// hits are the violations of the packages found in the cache
+	hits []rules.Violation
+
+	// analyze holds the IDs of the packages not found in the cache, and
+	// patterns loads them
+	analyze  map[string]bool
+	patterns []string
+
+	// keys holds the cache keys of the packages to store once analyzed
+	keys map[string]cache.Key
+
+	mu         sync.Mutex
+	files      map[string][]string
+	violations map[string][]rules.Violation
+}
+
+// lookupCache looks up the packages of a load group in the cache, from
+// their metadata and the export data of their dependencies, which the go
+// command keeps in its build cache. Packages found are restored into rs. It
+// returns nil when the packages can't be looked up; they are then analyzed
+// as if there was no cache.
+func (a *Analyzer) lookupCache(ctx context.Context, group *loadGroup, build BuildConfig, rs *runState) *cachePlan {
+	tool := toolID()
+	if tool == "" {
+		return nil
+	}
+
+	cfg := &packages.Config{
+		Context:    ctx,
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedDeps | packages.NeedExportFile,
+		Dir:        group.dir,
+		Tests:      true,
+		BuildFlags: build.buildFlags(),
+		Env:        build.env(a.env),
+	}
+	start := time.Now()
+	pkgs, err := packages.Load(cfg, group.patterns...)
+	rs.loaded(time.Since(start))
+	if err != nil || len(pkgs) == 0 {
+		return nil
+	}
+
+	plan := &cachePlan{
+		cache:      a.cache,
+		analyze:    make(map[string]bool),
+		keys:       make(map[string]cache.Key),
+		files:      make(map[string][]string),
+		violations: make(map[string][]rules.Violation),
+	}
+	exports := make(map[string]string)
+	paths := make(map[string]bool)
+
+	for _, pkg := range pkgs {
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+
+		key, ok := a.packageKey(pkg, build, tool, exports)
+		var entry cacheEntry
+		if ok && a.cache.Get(key, &entry) {
+			rs.restore(&entry)
+			plan.hits = append(plan.hits, entry.Violations...)
+			continue
+		}
+
+		rs.cacheMiss()
+		plan.analyze[pkg.ID] = true
+		if ok {
+			plan.keys[pkg.ID] = key
+		}
+		paths[basePath(pkg.ID)] = true
+	}
+
+	if len(plan.analyze) == 0 {
+		return plan
+	}
+	if paths["command-line-arguments"] {
+		// Packages named by their files can't be loaded by import path
+		plan.patterns = group.patterns
+		return plan
+	}
+	for path := range paths {
+		plan.patterns = append(plan.patterns, path)
+	}
+	sort.Strings(plan.patterns)
+	return plan
+}
+
+// basePath returns the import path of the package a package ID belongs to:
+// "p" for p itself and its test variants "p [p.test]" and "p_test [p.test]"
+func basePath(id string) string {
+	if i := strings.Index(id, " ["); i >= 0 && strings.HasSuffix(id, ".test]") {
+		return strings.TrimSuffix(id[i+2:], ".test]")
+	}
+	return id
+}
+
+// packageKey computes the cache key of a package. It reports false if the
+// package can't be cached, e.g. because it or a dependency doesn't build.
+func (a *Analyzer) packageKey(pkg *packages.Package, build BuildConfig, tool string, exports map[string]string) (cache.Key, bool) {
+	if len(pkg.Errors) > 0 {
+		return cache.Key{}, false
+	}
+
+	h := cache.NewHasher()
+	h.Add(cacheFormat, tool, build.String(), strings.Join(a.env, "\n"), pkg.ID)
+
+	for _, files := range [][]string{pkg.GoFiles, pkg.CompiledGoFiles} {
+		h.Add("files")
+		for _, filename := range files {
+			hash, err := cache.HashFile(filename)
+			if err != nil {
+				return cache.Key{}, false
+			}
+			h.Add(filename, hash)
+		}
+	}
+
+	for _, filename := range pkg.GoFiles {
+		fingerprint, ok := a.rulesFingerprint(filename)
+		if !ok {
+			return cache.Key{}, false
+		}
+		h.Add(fingerprint)
+	}
+
+	// SSA rules may look into the bodies of any dependency, so all of them
+	// are part of the key, not only the direct imports
+	for _, dep := range dependencies(pkg) {
+		if dep.PkgPath == "unsafe" {
+			h.Add(dep.ID)
+			continue
+		}
+		if dep.ExportFile == "" {
+			return cache.Key{}, false
+		}
+		hash, ok := exports[dep.ExportFile]
+		if !ok {
+			var err error
+			if hash, err = cache.HashFile(dep.ExportFile); err != nil {
+				return cache.Key{}, false
+			}
+			exports[dep.ExportFile] = hash
+		}
+		h.Add(dep.ID, hash)
+	}
+
+	return h.Sum(), true
+}
+
+// dependencies returns the packages pkg depends on, directly or not, sorted
+// by ID
+func dependencies(pkg *packages.Package) []*packages.Package {
+	seen := make(map[string]*packages.Package)
+	var visit func(p *packages.Package)
+	visit = func(p *packages.Package) {
+		for _, imp := range p.Imports {
+			if _, ok := seen[imp.ID]; !ok {
+				seen[imp.ID] = imp
+				visit(imp)
+			}
+		}
+	}
+	visit(pkg)
+
+	deps := make([]*packages.Package, 0, len(seen))
+	for _, dep := range seen {
+		deps = append(deps, dep)
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].ID < deps[j].ID })
+	return deps
+}
+
+// rulesFingerprint describes the rules run on filename and their
+// configuration. It reports false if their results can't be cached.
+func (a *Analyzer) rulesFingerprint(filename string) (string, bool) {
+	enabled, cfg, err := a.rulesFor(filename)
+	if err != nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	for _, rule := range enabled {
+		b.WriteString(rule.Name())
+		if cfg != nil {
+			data, err := json.Marshal(cfg.Rules[rule.Name()])
+			if err != nil {
+				return "", false
+			}
+			b.Write(data)
+		}
+		if fingerprinted, ok := rule.(rules.Fingerprinted); ok {
+			fingerprint := fingerprinted.Fingerprint()
+			if fingerprint == "" {
+				return "", false
+			}
+			b.WriteString(fingerprint)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), true
+}
+
+// analyzes reports whether pkg is to be analyzed rather than restored from
+// the cache. Without a plan every package is.
+func (p *cachePlan) analyzes(pkg *packages.Package) bool {
+	return p == nil || p.analyze[pkg.ID]
+}
+
+// skip prevents pkg from being stored, e.g. because it failed to build
+func (p *cachePlan) skip(pkg *packages.Package) {
+	if p != nil {
+		delete(p.keys, pkg.ID)
+	}
+}
+
+// collect returns tasks that also record their violations as those of pkg,
+// to be stored once all tasks have run
+func (p *cachePlan) collect(pkg *packages.Package, tasks []task) []task {
+	if p == nil {
+		return tasks
+	}
+	if _, ok := p.keys[pkg.ID]; !ok {
+		return tasks
+	}
+
+	p.files[pkg.ID] = pkg.GoFiles
+	collecting := make([]task, len(tasks))
+	for i, t := range tasks {
+		collecting[i] = func() []rules.Violation {
+			violations := t()
+			p.mu.Lock()
+			p.violations[pkg.ID] = append(p.violations[pkg.ID], violations...)
+			p.mu.Unlock()
+			return violations
+		}
+	}
+	return collecting
+}
+
+// store writes the collected violations of every cacheable package to the
+// cache. Results of rules that failed are not stored, and neither are those
+// of packages that fail to be written: the cache is an optimization only.
+func (p *cachePlan) store(rs *runState) {
+	if p == nil {
+		return
+	}
+
+	for id, files := range p.files {
+		key, ok := p.keys[id]
+		if !ok {
+			continue
+		}
+		violations := p.violations[id]
+		failed := false
+		for _, v := range violations {
+			failed = failed || v.Rule == InternalErrorRule
+		}
+		if failed {
+			continue
+		}
+		_ = p.cache.Put(key, &cacheEntry{
+			Violations: violations,
+			Files:      rs.sup.snapshot(files),
+		})
+	}
+}
+
+// restore records the files and suppression directives of a package found
+// in the cache, as if its rules had run
+func (rs *runState) restore(entry *cacheEntry) {
+	rs.sup.restore(entry.Files)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.cacheHits++
+	for _, file := range entry.Files {
+		rs.files[file.Filename] = true
+		for _, rule := range file.ActiveRules {
+			rs.ruleStats(rule)
+		}
+	}
+}
+
+// cacheMiss notes a package that wasn't found in the cache
+func (rs *runState) cacheMiss() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.cacheMisses++
+}
+
+// snapshot returns the suppression state of the given files that rules ran
+// on
+func (s *suppressions) snapshot(filenames []string) []cachedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var files []cachedFile
+	for _, filename := range filenames {
+		fs, ok := s.files[filename]
+		if !ok {
+			continue
+		}
+		file := cachedFile{Filename: filename, Invalid: fs.invalid}
+		for _, d := range fs.directives {
+			file.Directives = append(file.Directives, cachedDirective{Pos: d.pos, Rules: d.rules, FileLevel: d.fileLevel})
+		}
+		for rule := range fs.activeRules {
+			file.ActiveRules = append(file.ActiveRules, rule)
+		}
+		sort.Strings(file.ActiveRules)
+		files = append(files, file)
+	}
+	return files
+}
+
+// restore adds the suppression state of files taken from the cache. Files
+// already recorded keep their directives and gain the restored rules.
+func (s *suppressions) restore(files []cachedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, file := range files {
+		fs, ok := s.files[file.Filename]
+		if !ok {
+			fs = &fileSuppressions{activeRules: make(map[string]bool), invalid: file.Invalid}
+			for _, d := range file.Directives {
+				fs.directives = append(fs.directives, &directive{
+					pos:       d.Pos,
+					rules:     d.Rules,
+					fileLevel: d.FileLevel,
+					used:      make(map[string]bool),
+				})
+			}
+			s.files[file.Filename] = fs
+		}
+		for _, rule := range file.ActiveRules {
+			fs.activeRules[rule] = true
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Arneball/goasted/analyzer"
+	"github.com/Arneball/goasted/cache"
+	"github.com/Arneball/goasted/config"
+)
+
+func TestAnalyze_Cache(t *testing.T) {
+	dir := writeModule(t)
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	analyze := func(opts analyzer.Options) *analyzer.Result {
+		t.Helper()
+		opts.Dir = dir
+		opts.Cache = c
+		result, err := analyzer.Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+		return result
+	}
+
+	// store, its test variant and broken are analyzed
+	first := analyze(analyzer.Options{})
+	if first.CacheHits != 0 || first.CacheMisses != 3 {
+		t.Errorf("Expected 3 misses in an empty cache, got %d hits and %d misses", first.CacheHits, first.CacheMisses)
+	}
+
+	// broken doesn't type-check and is never cached
+	second := analyze(analyzer.Options{})
+	if second.CacheHits != 2 || second.CacheMisses != 1 {
+		t.Errorf("Expected 2 hits and 1 miss, got %d hits and %d misses", second.CacheHits, second.CacheMisses)
+	}
+	if !reflect.DeepEqual(first.Violations, second.Violations) {
+		t.Errorf("Expected cached violations to match\nfirst:  %+v\nsecond: %+v", first.Violations, second.Violations)
+	}
+	if second.Suppressed != first.Suppressed || second.Files != first.Files {
+		t.Errorf("Expected %d suppressed and %d files from the cache, got %d and %d", first.Suppressed, first.Files, second.Suppressed, second.Files)
+	}
+	if _, ok := second.Rules["sql-context-required"]; !ok {
+		t.Errorf("Expected restored rules to be listed, got %v", second.Rules)
+	}
+
+	// Editing a test file only invalidates the test variant
+	testFile := filepath.Join(dir, "store", "store_test.go")
+	if err := os.WriteFile(testFile, []byte("package store\n\nimport \"testing\"\n\nfunc TestOther(t *testing.T) {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	third := analyze(analyzer.Options{})
+	if third.CacheHits != 1 || third.CacheMisses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses after editing a test file, got %d hits and %d misses", third.CacheHits, third.CacheMisses)
+	}
+	if !reflect.DeepEqual(first.Violations, third.Violations) {
+		t.Errorf("Expected the same violations after editing a test file\nfirst: %+v\nthird: %+v", first.Violations, third.Violations)
+	}
+
+	// So does changing the configuration of a rule
+	fourth := analyze(analyzer.Options{Config: &config.File{
+		Rules: map[string]config.RuleConfig{"sql-context-required": {Severity: "warning"}},
+	}})
+	if fourth.CacheHits != 0 {
+		t.Errorf("Expected no hits after changing the configuration, got %d", fourth.CacheHits)
+	}
+}
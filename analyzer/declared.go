@@ -0,0 +1,180 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/pattern"
+	"github.com/Arneball/goasted/plugin"
+	"github.com/Arneball/goasted/rules"
+)
+
+// declaredRules holds the rules declared in configuration files. Each
+// declaration is instantiated once, however many directories it applies
+// to, and each configuration gets the registry of the rules that may run
+// under it.
+type declaredRules struct {
+	mu         sync.Mutex
+	rules      map[string]rules.Checker
+	plugins    []*plugin.Rule
+	registries map[*config.Config]*rules.Registry
+}
+
+// RegistryFor returns the rules that may run on the files of dir: the
+// analyzer's rules plus the plugins and pattern rules declared in the
+// configuration of dir,
+// restricted to the rules selected with SetRules. Rules disabled by the
+// configuration are part of it.
+func (a *Analyzer) RegistryFor(dir string) (*rules.Registry, error) {
+	if a.config == nil {
+		return a.registryFor(nil)
+	}
+	cfg, err := a.config.ForDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return a.registryFor(cfg)
+}
+
+// registryFor returns the rules that may run under cfg, which is nil when
+// the analyzer has no configuration. Registries are cached per
+// configuration.
+func (a *Analyzer) registryFor(cfg *config.Config) (*rules.Registry, error) {
+	d := &a.declared
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if registry, ok := d.registries[cfg]; ok {
+		return registry, nil
+	}
+
+	registry := rules.NewRegistry()
+	for _, rule := range a.registry.GetCheckers() {
+		if a.selected(rule.Name()) {
+			registry.Register(rule)
+		}
+	}
+	if cfg != nil {
+		for _, name := range sortedKeys(cfg.Plugins) {
+			// A rule registered by the caller takes precedence
+			if a.registry.GetChecker(name) != nil || !a.selected(name) {
+				continue
+			}
+			rule, err := d.plugin(name, cfg.Plugins[name], a.ruleTimeout)
+			if err != nil {
+				return nil, err
+			}
+			registry.Register(rule)
+		}
+		for _, name := range sortedKeys(cfg.Patterns) {
+			if a.registry.GetChecker(name) != nil || !a.selected(name) {
+				continue
+			}
+			rule, err := d.pattern(name, cfg.Patterns[name])
+			if err != nil {
+				return nil, err
+			}
+			registry.Register(rule)
+		}
+	}
+
+	if d.registries == nil {
+		d.registries = make(map[*config.Config]*rules.Registry)
+	}
+	d.registries[cfg] = registry
+	return registry, nil
+}
+
+// plugin returns the rule running the plugin declared as name, creating it
+// on first use. The caller holds d.mu.
+func (d *declaredRules) plugin(name string, pc config.PluginConfig, timeout time.Duration) (rules.Checker, error) {
+	key, err := declarationKey("plugin", name, pc)
+	if err != nil {
+		return nil, err
+	}
+	if rule, ok := d.rules[key]; ok {
+		return rule, nil
+	}
+
+	rule := plugin.New(name, pc)
+	rule.SetTimeout(timeout)
+	d.plugins = append(d.plugins, rule)
+	d.store(key, rule)
+	return rule, nil
+}
+
+// pattern returns the rule compiled from the pattern declared as name,
+// compiling it on first use. The caller holds d.mu.
+func (d *declaredRules) pattern(name string, pc config.PatternConfig) (rules.Checker, error) {
+	key, err := declarationKey("pattern", name, pc)
+	if err != nil {
+		return nil, err
+	}
+	if rule, ok := d.rules[key]; ok {
+		return rule, nil
+	}
+
+	rule, err := pattern.New(name, pc)
+	if err != nil {
+		return nil, err
+	}
+	d.store(key, rule)
+	return rule, nil
+}
+
+// store caches the rule instantiated for a declaration. The caller holds
+// d.mu.
+func (d *declaredRules) store(key string, rule rules.Checker) {
+	if d.rules == nil {
+		d.rules = make(map[string]rules.Checker)
+	}
+	d.rules[key] = rule
+}
+
+// declarationKey identifies a declaration by kind, name and settings, so
+// that identical declarations share a rule
+func declarationKey(kind, name string, declaration any) (string, error) {
+	data, err := json.Marshal(declaration)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s %q: %w", kind, name, err)
+	}
+	return kind + "\x00" + name + "\x00" + string(data), nil
+}
+
+// selected reports whether the named rule was selected with SetRules
+func (a *Analyzer) selected(name string) bool {
+	return a.only == nil || a.only[name]
+}
+
+// Close stops the plugin processes started for rules declared in
+// configuration files. The analyzer can still be used; plugins are then
+// started again.
+func (a *Analyzer) Close() error {
+	d := &a.declared
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var errs []error
+	for _, p := range d.plugins {
+		if err := p.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	d.rules, d.plugins, d.registries = nil, nil, nil
+	return errors.Join(errs...)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// writeTree writes files, keyed by slash-separated paths, under a new
+// directory
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// analyzeTree analyzes dir/... with the built-in rules and the
+// configuration files of the tree, and returns the violated rules by file
+// relative to dir
+func analyzeTree(t *testing.T, dir string) map[string][]string {
+	t.Helper()
+
+	registry := rules.DefaultRegistry()
+	a := New(registry)
+	a.SetConfig(config.NewResolver(registry))
+	t.Cleanup(func() {
+		if err := a.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	})
+
+	violations, err := a.Analyze(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	byFile := make(map[string][]string)
+	for _, v := range violations {
+		rel, err := filepath.Rel(dir, v.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byFile[filepath.ToSlash(rel)] = append(byFile[filepath.ToSlash(rel)], v.Rule)
+	}
+	for _, names := range byFile {
+		sort.Strings(names)
+	}
+	return byFile
+}
+
+func TestAnalyze_NestedPluginDeclaration(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	dir := writeTree(t, map[string]string{
+		"go.mod":     "module example.com/tree\n\ngo 1.22\n",
+		"root.go":    "package tree\n",
+		"sub/sub.go": "package sub\n",
+		"sub/plugin": "#!/bin/sh\nwhile read -r line; do\n\techo '{\"version\":1,\"violations\":[{\"line\":1,\"message\":\"checked\"}]}'\ndone\n",
+		"sub/.goasted.yaml": `plugins:
+  sub-plugin:
+    command: ["./plugin"]
+`,
+	})
+
+	// The plugin is declared below the analyzed directory, and only runs on
+	// the files under its configuration file
+	got := analyzeTree(t, dir)
+	if len(got) != 1 || len(got["sub/sub.go"]) != 1 || got["sub/sub.go"][0] != "sub-plugin" {
+		t.Errorf("Expected sub-plugin to report sub/sub.go only, got %v", got)
+	}
+}
+
+func TestAnalyze_NestedPatternDeclaration(t *testing.T) {
+	src := "package %s\n\nfunc F() {\n\tprintln(\"debug\")\n}\n"
+	dir := writeTree(t, map[string]string{
+		"go.mod":         "module example.com/tree\n\ngo 1.22\n",
+		"root.go":        fmt.Sprintf(src, "tree"),
+		"sub/sub.go":     fmt.Sprintf(src, "sub"),
+		"other/other.go": fmt.Sprintf(src, "other"),
+		"sub/.goasted.yaml": `patterns:
+  no-println:
+    pattern: println($*args)
+    message: remove debug output
+`,
+		// The same name declared differently in another subtree
+		"other/.goasted.yaml": `patterns:
+  no-println:
+    pattern: print($*args)
+    message: remove debug output
+`,
+	})
+
+	// Analyzing the root runs each declaration on its own subtree only
+	got := analyzeTree(t, dir)
+	if len(got) != 1 || len(got["sub/sub.go"]) != 1 || got["sub/sub.go"][0] != "no-println" {
+		t.Errorf("Expected no-println to report sub/sub.go only, got %v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// InternalErrorRule is the rule name reported when a rule panics or runs
+// out of its time budget
+const InternalErrorRule = "internal-error"
+
+// internalError creates the violation reported for a failing rule
+func internalError(file, message string) rules.Violation {
+	return rules.Violation{
+		File:     file,
+		Rule:     InternalErrorRule,
+		Message:  message,
+		Severity: rules.SeverityError,
+	}
+}
+
+// runRule runs one rule's check on target, a file or package, isolating the
+// rest of the analysis from it: a panic is recovered and reported as an
+// internal error, and so is exceeding the per-rule time budget. Rules can't
+// be interrupted, so a rule over budget is abandoned and left to finish in
+// the background. The time spent is added to the rule's statistics in rs.
+func (a *Analyzer) runRule(rs *runState, rule rules.Checker, file, target string, check func() []rules.Violation) []rules.Violation {
+	start := time.Now()
+	defer func() {
+		rs.timed(rule.Name(), time.Since(start))
+	}()
+
+	if a.ruleTimeout <= 0 {
+		return recoverRule(rule, file, target, check)
+	}
+
+	done := make(chan []rules.Violation, 1)
+	go func() {
+		done <- recoverRule(rule, file, target, check)
+	}()
+
+	timer := time.NewTimer(a.ruleTimeout)
+	defer timer.Stop()
+
+	select {
+	case violations := <-done:
+		return violations
+	case <-timer.C:
+		return []rules.Violation{internalError(file, fmt.Sprintf("rule %s exceeded its time budget of %s on %s", rule.Name(), a.ruleTimeout, target))}
+	}
+}
+
+// recoverRule runs check, turning a panic into an internal error that
+// carries the rule name, the target and the stack
+func recoverRule(rule rules.Checker, file, target string, check func() []rules.Violation) (violations []rules.Violation) {
+	defer func() {
+		if r := recover(); r != nil {
+			// Drop the "goroutine N [running]:" header so that the same
+			// panic in several package variants deduplicates
+			_, stack, _ := strings.Cut(string(debug.Stack()), "\n")
+			violations = []rules.Violation{internalError(file, fmt.Sprintf("rule %s panicked on %s: %v\n%s", rule.Name(), target, r, stack))}
+		}
+	}()
+	return check()
+}
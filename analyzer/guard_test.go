@@ -0,0 +1,113 @@
+package analyzer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// panicRule panics on every file
+type panicRule struct{}
+
+func (panicRule) Name() string                    { return "panics" }
+func (panicRule) Description() string             { return "test rule" }
+func (panicRule) DefaultSeverity() rules.Severity { return rules.SeverityWarning }
+
+func (panicRule) Check(ctx *rules.Context) []rules.Violation {
+	panic("boom")
+}
+
+// slowRule blocks until released
+type slowRule struct {
+	release chan struct{}
+}
+
+func (slowRule) Name() string                    { return "slow" }
+func (slowRule) Description() string             { return "test rule" }
+func (slowRule) DefaultSeverity() rules.Severity { return rules.SeverityWarning }
+
+func (r slowRule) CheckPackage(ctx *rules.PackageContext) []rules.Violation {
+	<-r.release
+	return nil
+}
+
+// countRules counts violations per rule
+func countRules(violations []rules.Violation) map[string]int {
+	counts := make(map[string]int)
+	for _, v := range violations {
+		counts[v.Rule]++
+	}
+	return counts
+}
+
+func TestAnalyze_RecoversPanics(t *testing.T) {
+	registry := rules.NewRegistry()
+	registry.Register(panicRule{})
+	registry.Register(rules.NewSqlContextRule())
+
+	violations, err := New(registry).Analyze(context.Background(), filepath.Join("testdata", "multifile"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	counts := countRules(violations)
+	if counts["sql-context-required"] != 2 {
+		t.Errorf("Expected other rules to keep running, got %v", violations)
+	}
+	if counts[InternalErrorRule] != 2 {
+		t.Fatalf("Expected an internal error per file, got %v", violations)
+	}
+
+	for _, v := range violations {
+		if v.Rule != InternalErrorRule {
+			continue
+		}
+		if v.Severity != rules.SeverityError {
+			t.Errorf("Expected internal errors to be errors, got %s", v.Severity)
+		}
+		if !strings.Contains(v.Message, "rule panics panicked on "+v.File+": boom") || !strings.Contains(v.Message, "recoverRule") {
+			t.Errorf("Expected rule, file and stack in message, got %q", v.Message)
+		}
+	}
+}
+
+func TestAnalyze_RuleTimeout(t *testing.T) {
+	rule := slowRule{release: make(chan struct{})}
+	defer close(rule.release)
+
+	registry := rules.NewRegistry()
+	registry.Register(rule)
+	registry.Register(rules.NewSqlContextRule())
+
+	a := New(registry)
+	a.SetRuleTimeout(50 * time.Millisecond)
+
+	violations, err := a.Analyze(context.Background(), filepath.Join("testdata", "multifile"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	counts := countRules(violations)
+	if counts["sql-context-required"] != 2 || counts[InternalErrorRule] != 1 {
+		t.Fatalf("Expected 2 sql violations and 1 internal error, got %v", violations)
+	}
+	for _, v := range violations {
+		if v.Rule == InternalErrorRule && !strings.Contains(v.Message, "rule slow exceeded its time budget of 50ms on package multifile") {
+			t.Errorf("Unexpected message: %q", v.Message)
+		}
+	}
+}
+
+func TestAnalyze_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := New(rules.DefaultRegistry()).Analyze(ctx, filepath.Join("testdata", "multifile"))
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+}
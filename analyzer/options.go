@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Arneball/goasted/cache"
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// Options configures an analysis run started with Analyze. The zero value
+// analyzes ./... in the current directory with the built-in rules and the
+// project's configuration files.
+type Options struct {
+	// Dir is the directory patterns are relative to. Empty means the
+	// current directory.
+	Dir string
+
+	// Paths lists files and directories to analyze, relative to Dir.
+	// Directories are analyzed recursively, including nested modules.
+	Paths []string
+
+	// Patterns lists package patterns, interpreted like the go tool does
+	// relative to Dir. Without paths or patterns, ./... is analyzed.
+	Patterns []string
+
+	// Env is added to the environment of the go command that loads
+	// packages, e.g. GOFLAGS=-mod=vendor
+	Env []string
+
+	// Tags are the build tags to load packages with
+	Tags []string
+
+	// Platforms lists os/arch pairs, e.g. linux/amd64, to analyze the code
+	// for. Empty means the host platform.
+	Platforms []string
+
+	// Registry holds the rules to run. Nil means rules.DefaultRegistry.
+	// The plugins and pattern rules declared in configuration run on top
+	// of it, each on the files its configuration applies to.
+	Registry *rules.Registry
+
+	// Rules restricts the run to the named rules, of the registry or
+	// declared in the configuration of Dir
+	Rules []string
+
+	// IgnoreConfigFiles skips reading .goasted.yaml and friends
+	IgnoreConfigFiles bool
+
+	// Config is layered on top of the configuration files, or used alone
+	// with IgnoreConfigFiles. Its globs are relative to Dir.
+	Config *config.File
+
+	// FileFilter, if set, skips the files it returns false for
+	FileFilter func(filename string) bool
+
+	// Jobs is the number of files or package rules analyzed in parallel.
+	// Zero means GOMAXPROCS.
+	Jobs int
+
+	// RuleTimeout is the time budget of a rule on a single file or package.
+	// Zero means no budget.
+	RuleTimeout time.Duration
+
+	// ReportUnusedIgnores reports ignore directives that suppress nothing
+	ReportUnusedIgnores bool
+
+	// Cache, if set, holds the violations of packages analyzed before.
+	// Unchanged packages are not analyzed again.
+	Cache *cache.Cache
+}
+
+// Analyze runs an analysis configured by opts
+func Analyze(ctx context.Context, opts Options) (*Result, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	registry := opts.Registry
+	if registry == nil {
+		registry = rules.DefaultRegistry()
+	}
+
+	// Configuration is validated against all rules, so that it may
+	// mention rules left out by Rules
+	resolver := config.NewResolver(registry)
+	resolver.SetDiscovery(!opts.IgnoreConfigFiles)
+	if opts.Config != nil {
+		if err := resolver.SetOverride(dir, opts.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := resolver.ForDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range opts.Rules {
+		if registry.GetChecker(name) == nil && !cfg.Declares(name) {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+	}
+
+	builds, err := BuildMatrix(strings.Join(opts.Tags, ","), strings.Join(opts.Platforms, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := opts.patterns(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	a := New(registry)
+	defer func() { _ = a.Close() }()
+	a.SetConfig(resolver)
+	if len(opts.Rules) > 0 {
+		a.SetRules(opts.Rules)
+	}
+	a.SetBuildConfigs(builds)
+	a.SetEnv(opts.Env)
+	a.SetFileFilter(opts.FileFilter)
+	a.SetConcurrency(opts.Jobs)
+	a.SetRuleTimeout(opts.RuleTimeout)
+	a.SetReportUnusedIgnores(opts.ReportUnusedIgnores)
+	a.SetCache(opts.Cache)
+
+	return a.Run(ctx, dir, patterns)
+}
+
+// patterns merges Paths into Patterns, as absolute directory patterns and
+// files
+func (opts Options) patterns(dir string) ([]string, error) {
+	patterns := append([]string(nil), opts.Patterns...)
+	for _, path := range opts.Paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat path: %w", err)
+		}
+		if info.IsDir() {
+			abs = filepath.Join(abs, "...")
+		}
+		patterns = append(patterns, abs)
+	}
+
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	return patterns, nil
+}
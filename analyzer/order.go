@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// violationKey identifies a violation across package variants. With tests
+// enabled, a non-test file is loaded both in pkg and in "pkg [pkg.test]" and
+// every rule reports on it twice.
+type violationKey struct {
+	file         string
+	line, column int
+	rule         string
+	message      string
+}
+
+// dedupe drops violations reported more than once for the same position,
+// rule and message, keeping the first and merging the configurations all
+// copies were found in
+func dedupe(violations []rules.Violation) []rules.Violation {
+	seen := make(map[violationKey]int, len(violations))
+	kept := violations[:0]
+	for _, v := range violations {
+		key := violationKey{file: v.File, line: v.Line, column: v.Column, rule: v.Rule, message: v.Message}
+		if i, ok := seen[key]; ok {
+			kept[i].Configurations = mergeConfigurations(kept[i].Configurations, v.Configurations)
+			continue
+		}
+		seen[key] = len(kept)
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// mergeConfigurations appends the configurations in b missing from a
+func mergeConfigurations(a, b []string) []string {
+	for _, config := range b {
+		if !slices.Contains(a, config) {
+			a = append(a, config)
+		}
+	}
+	return a
+}
+
+// sortViolations sorts violations by file, line, column and rule, so that
+// reports are stable between runs despite concurrent analysis
+func sortViolations(violations []rules.Violation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+}
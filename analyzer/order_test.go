@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func TestDedupe(t *testing.T) {
+	violations := []rules.Violation{
+		{File: "a.go", Line: 1, Column: 2, Rule: "r", Message: "m"},
+		{File: "a.go", Line: 1, Column: 2, Rule: "r", Message: "m"},
+		{File: "a.go", Line: 1, Column: 2, Rule: "r", Message: "other"},
+		{File: "a.go", Line: 1, Column: 2, Rule: "s", Message: "m"},
+	}
+
+	kept := dedupe(violations)
+	if len(kept) != 3 {
+		t.Errorf("Expected 3 violations, got %d: %v", len(kept), kept)
+	}
+}
+
+func TestSortViolations(t *testing.T) {
+	violations := []rules.Violation{
+		{File: "b.go", Line: 1, Column: 1, Rule: "r"},
+		{File: "a.go", Line: 2, Column: 1, Rule: "r"},
+		{File: "a.go", Line: 1, Column: 5, Rule: "r"},
+		{File: "a.go", Line: 1, Column: 5, Rule: "q"},
+		{File: "a.go", Line: 1, Column: 1, Rule: "z"},
+	}
+
+	sortViolations(violations)
+
+	expected := []rules.Violation{
+		{File: "a.go", Line: 1, Column: 1, Rule: "z"},
+		{File: "a.go", Line: 1, Column: 5, Rule: "q"},
+		{File: "a.go", Line: 1, Column: 5, Rule: "r"},
+		{File: "a.go", Line: 2, Column: 1, Rule: "r"},
+		{File: "b.go", Line: 1, Column: 1, Rule: "r"},
+	}
+	for i := range expected {
+		got := violations[i]
+		if got.File != expected[i].File || got.Line != expected[i].Line || got.Column != expected[i].Column || got.Rule != expected[i].Rule {
+			t.Errorf("Expected %+v at %d, got %+v", expected[i], i, got)
+		}
+	}
+}
+
+func TestAnalyze_NoDuplicatesFromTestVariants(t *testing.T) {
+	registry := rules.NewRegistry()
+	registry.Register(rules.NewSqlContextRule())
+
+	violations, err := New(registry).Analyze(context.Background(), filepath.Join("testdata", "withtests"))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	// store.go is part of both withtests and withtests [withtests.test]
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %d: %v", len(violations), violations)
+	}
+	if filepath.Base(violations[0].File) != "store.go" || filepath.Base(violations[1].File) != "store_test.go" {
+		t.Errorf("Expected violations sorted by file, got %v", violations)
+	}
+}
@@ -0,0 +1,208 @@
+package analyzer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// loadGroup is a set of patterns loaded together by one packages.Load call
+// from the root of the module they belong to
+type loadGroup struct {
+	dir      string
+	patterns []string
+
+	// fallbackDirs are the directories to analyze file by file if loading
+	// fails, as happens outside of any module
+	fallbackDirs []string
+}
+
+// loadPlan is the result of resolving package patterns
+type loadPlan struct {
+	groups []*loadGroup
+	files  []string
+}
+
+// isLocalPattern reports whether a pattern names a directory rather than an
+// import path, following the go tool's rules
+func isLocalPattern(pattern string) bool {
+	return pattern == "." || pattern == ".." || filepath.IsAbs(pattern) ||
+		strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") ||
+		strings.HasPrefix(pattern, "."+string(filepath.Separator))
+}
+
+// planLoads resolves patterns relative to dir into load groups. Local
+// patterns ending in /... also cover the modules nested below their
+// directory and the modules used by go.work files there, which the go tool
+// would skip. Patterns naming .go files are returned as files.
+func planLoads(dir string, patterns []string) (*loadPlan, error) {
+	plan := &loadPlan{}
+	groups := make(map[string]*loadGroup)
+	group := func(dir string) *loadGroup {
+		g, ok := groups[dir]
+		if !ok {
+			g = &loadGroup{dir: dir}
+			groups[dir] = g
+			plan.groups = append(plan.groups, g)
+		}
+		return g
+	}
+	addPattern := func(g *loadGroup, pattern string) {
+		for _, p := range g.patterns {
+			if p == pattern {
+				return
+			}
+		}
+		g.patterns = append(g.patterns, pattern)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range patterns {
+		if !isLocalPattern(pattern) {
+			if strings.HasSuffix(pattern, ".go") {
+				plan.files = append(plan.files, filepath.Join(absDir, pattern))
+				continue
+			}
+			// Import paths resolve against the module of dir
+			addPattern(group(absDir), pattern)
+			continue
+		}
+
+		path := pattern
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(absDir, path)
+		}
+		root, recursive := strings.CutSuffix(filepath.ToSlash(path), "/...")
+		root = filepath.Clean(filepath.FromSlash(root))
+
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			plan.files = append(plan.files, root)
+			continue
+		}
+
+		if !recursive {
+			g := group(moduleRoot(root, root))
+			addPattern(g, root)
+			g.fallbackDirs = append(g.fallbackDirs, root)
+			continue
+		}
+
+		modules, err := findModules(root)
+		if err != nil {
+			return nil, err
+		}
+
+		// The directory itself is covered by its enclosing module. Outside
+		// of any module it's only analyzed if there's nothing else to load.
+		enclosing := moduleRoot(root, "")
+		if enclosing != "" || len(modules) == 0 {
+			g := group(moduleRoot(root, root))
+			addPattern(g, filepath.Join(root, "..."))
+			g.fallbackDirs = append(g.fallbackDirs, root)
+		}
+		for _, module := range modules {
+			if module == enclosing {
+				continue
+			}
+			addPattern(group(module), filepath.Join(module, "..."))
+		}
+	}
+
+	return plan, nil
+}
+
+// moduleRoot returns the directory of the go.mod governing dir, or def if
+// dir is not inside a module
+func moduleRoot(dir, def string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return def
+		}
+	}
+}
+
+// findModules returns the module roots below dir, including dir itself,
+// plus the modules used by go.work files found there. Like the go tool it
+// skips vendor and testdata directories and those starting with . or _.
+func findModules(dir string) ([]string, error) {
+	seen := make(map[string]bool)
+	var modules []string
+	add := func(module string) {
+		if !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch d.Name() {
+		case "go.mod":
+			add(filepath.Dir(path))
+		case "go.work":
+			uses, err := workspaceModules(path)
+			if err != nil {
+				return err
+			}
+			for _, use := range uses {
+				add(use)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover modules in %s: %w", dir, err)
+	}
+
+	sort.Strings(modules)
+	return modules, nil
+}
+
+// workspaceModules returns the absolute module directories a go.work file
+// uses
+func workspaceModules(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	work, err := modfile.ParseWork(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var modules []string
+	for _, use := range work.Use {
+		dir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(path), dir)
+		}
+		modules = append(modules, filepath.Clean(dir))
+	}
+	return modules, nil
+}
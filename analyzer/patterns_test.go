@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// analyzedFiles runs the sql rule on patterns relative to dir and returns
+// the base names of the files with violations
+func analyzedFiles(t *testing.T, dir string, patterns ...string) []string {
+	t.Helper()
+
+	registry := rules.NewRegistry()
+	registry.Register(rules.NewSqlContextRule())
+
+	violations, err := New(registry).AnalyzePatterns(context.Background(), dir, patterns)
+	if err != nil {
+		t.Fatalf("AnalyzePatterns failed: %v", err)
+	}
+
+	var files []string
+	for _, v := range violations {
+		files = append(files, filepath.Base(v.File))
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestAnalyzePatterns_NestedModules(t *testing.T) {
+	dir := filepath.Join("testdata", "nested")
+
+	tests := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{"./..."}, []string{"lib.go", "root.go", "sub.go"}},
+		{[]string{"."}, []string{"root.go"}},
+		{[]string{"./lib/...", "./sub/..."}, []string{"lib.go", "sub.go"}},
+		{[]string{"nested/lib"}, []string{"lib.go"}},
+		{[]string{"./sub/sub.go"}, []string{"sub.go"}},
+	}
+
+	for _, tt := range tests {
+		files := analyzedFiles(t, dir, tt.patterns...)
+		if len(files) != len(tt.expected) {
+			t.Errorf("%v: expected %v, got %v", tt.patterns, tt.expected, files)
+			continue
+		}
+		for i := range files {
+			if files[i] != tt.expected[i] {
+				t.Errorf("%v: expected %v, got %v", tt.patterns, tt.expected, files)
+				break
+			}
+		}
+	}
+}
+
+func TestAnalyzePatterns_Workspace(t *testing.T) {
+	// The go command rejects -mod=mod in workspace mode
+	t.Setenv("GOFLAGS", "")
+
+	// The workspace root is not a module itself, and b only sees the
+	// *sql.DB field of a.Store through the workspace
+	files := analyzedFiles(t, filepath.Join("testdata", "workspace"), "./...")
+	if len(files) != 1 || files[0] != "b.go" {
+		t.Errorf("Expected a violation in b.go, got %v", files)
+	}
+}
+
+func TestPlanLoads_GroupsByModule(t *testing.T) {
+	dir, err := filepath.Abs(filepath.Join("testdata", "nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := planLoads(dir, []string{"./...", "./lib"})
+	if err != nil {
+		t.Fatalf("planLoads failed: %v", err)
+	}
+
+	if len(plan.groups) != 2 {
+		t.Fatalf("Expected 2 load groups, got %d", len(plan.groups))
+	}
+	if plan.groups[0].dir != dir || len(plan.groups[0].patterns) != 2 {
+		t.Errorf("Expected both patterns in the outer module, got %+v", plan.groups[0])
+	}
+	if plan.groups[1].dir != filepath.Join(dir, "sub") {
+		t.Errorf("Expected the nested module in its own group, got %+v", plan.groups[1])
+	}
+}
@@ -0,0 +1,60 @@
+package analyzer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// task is a unit of analysis work, a file or a package rule
+type task func() []rules.Violation
+
+// runTasks runs tasks on at most workers goroutines and returns all their
+// violations. Once ctx is done no further tasks are started; running tasks
+// are waited for and ctx's error is returned.
+func runTasks(ctx context.Context, workers int, tasks []task) ([]rules.Violation, error) {
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	queue := make(chan task)
+	results := make(chan []rules.Violation, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				results <- t()
+			}
+		}()
+	}
+
+	go func() {
+	dispatch:
+		for _, t := range tasks {
+			if ctx.Err() != nil {
+				break
+			}
+			select {
+			case queue <- t:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	var violations []rules.Violation
+	for v := range results {
+		violations = append(violations, v...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func TestRunTasks_BoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+
+	var tasks []task
+	for i := 0; i < 20; i++ {
+		tasks = append(tasks, func() []rules.Violation {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			return []rules.Violation{{Rule: "r"}}
+		})
+	}
+
+	done := make(chan []rules.Violation)
+	go func() {
+		violations, _ := runTasks(context.Background(), 3, tasks)
+		done <- violations
+	}()
+	close(release)
+
+	violations := <-done
+	if len(violations) != 20 {
+		t.Errorf("Expected 20 violations, got %d", len(violations))
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("Expected at most 3 concurrent tasks, got %d", p)
+	}
+}
+
+func TestRunTasks_NoTasks(t *testing.T) {
+	violations, err := runTasks(context.Background(), 4, nil)
+	if err != nil || len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v, %v", violations, err)
+	}
+}
+
+func TestRunTasks_StopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var started atomic.Int32
+	var tasks []task
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, func() []rules.Violation {
+			started.Add(1)
+			cancel()
+			return nil
+		})
+	}
+
+	if _, err := runTasks(ctx, 1, tasks); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if n := started.Load(); n >= 10 {
+		t.Errorf("Expected remaining tasks to be skipped, got %d started", n)
+	}
+}
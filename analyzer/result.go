@@ -0,0 +1,202 @@
+package analyzer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// Result is the outcome of an analysis run
+type Result struct {
+	// Violations are the violations found, sorted by position
+	Violations []rules.Violation
+
+	// LoadErrors lists the packages that failed to load or type-check.
+	// Their results may be incomplete.
+	LoadErrors []PackageError
+
+	// Files is the number of files rules ran on
+	Files int
+
+	// Suppressed is the number of violations silenced by ignore directives
+	Suppressed int
+
+	// Rules holds statistics per rule name, for every rule that ran
+	Rules map[string]RuleStats
+
+	// Load is the time spent loading and parsing packages, summed over
+	// build configurations
+	Load time.Duration
+
+	// TypeCheck is the time spent type-checking each analyzed package by
+	// import path, summed over build configurations and test variants.
+	// Dependencies are read from export data and not included.
+	TypeCheck map[string]time.Duration
+
+	// CacheHits and CacheMisses count the packages whose violations were
+	// and weren't found in the cache, when one is set
+	CacheHits, CacheMisses int
+}
+
+// PackageError is a package that failed to load, with the reasons
+type PackageError struct {
+	// Package is the import path, or the directory analyzed file by file
+	// when loading failed altogether
+	Package string
+
+	Errors []string
+}
+
+// RuleStats are the statistics of one rule in a run
+type RuleStats struct {
+	// Runs is the number of files and packages the rule checked
+	Runs int
+
+	// Duration is the time spent in the rule, summed over all runs
+	Duration time.Duration
+
+	// Max is the time of the slowest run
+	Max time.Duration
+
+	// Violations is the number of violations reported, after suppression
+	Violations int
+
+	// Suppressed is the number of violations silenced by ignore directives
+	Suppressed int
+}
+
+// runState is the state shared by the parts of one analysis run
+type runState struct {
+	sup *suppressions
+
+	mu         sync.Mutex
+	files      map[string]bool
+	rules      map[string]*RuleStats
+	loadErrors map[string][]string
+	load       time.Duration
+	typeCheck  map[string]time.Duration
+
+	cacheHits, cacheMisses int
+}
+
+// newRunState creates the state of a new run
+func newRunState() *runState {
+	return &runState{
+		sup:        newSuppressions(),
+		files:      make(map[string]bool),
+		rules:      make(map[string]*RuleStats),
+		loadErrors: make(map[string][]string),
+		typeCheck:  make(map[string]time.Duration),
+	}
+}
+
+// analyzed notes that rules ran on filename
+func (rs *runState) analyzed(filename string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.files[filename] = true
+}
+
+// timed adds one run of a rule taking d
+func (rs *runState) timed(rule string, d time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	stats := rs.ruleStats(rule)
+	stats.Runs++
+	stats.Duration += d
+	if d > stats.Max {
+		stats.Max = d
+	}
+}
+
+// loaded adds a packages.Load call taking d
+func (rs *runState) loaded(d time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.load += d
+}
+
+// typeChecked adds the type-checking of pkg taking d
+func (rs *runState) typeChecked(pkg string, d time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.typeCheck[pkg] += d
+}
+
+// ruleStats returns the statistics of rule, creating them. The caller holds
+// rs.mu.
+func (rs *runState) ruleStats(rule string) *RuleStats {
+	stats, ok := rs.rules[rule]
+	if !ok {
+		stats = &RuleStats{}
+		rs.rules[rule] = stats
+	}
+	return stats
+}
+
+// loadError records why a package failed to load. Test variants of a
+// package report the same errors again; they are kept once.
+func (rs *runState) loadError(pkg string, errs []string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for _, err := range errs {
+		known := false
+		for _, e := range rs.loadErrors[pkg] {
+			known = known || e == err
+		}
+		if !known {
+			rs.loadErrors[pkg] = append(rs.loadErrors[pkg], err)
+		}
+	}
+}
+
+// result builds the Result of the run from the final violations
+func (rs *runState) result(violations []rules.Violation, suppressed map[string]int) *Result {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	result := &Result{
+		Violations: violations,
+		Files:      len(rs.files),
+		Rules:      make(map[string]RuleStats, len(rs.rules)),
+		Load:       rs.load,
+		TypeCheck:  make(map[string]time.Duration, len(rs.typeCheck)),
+
+		CacheHits:   rs.cacheHits,
+		CacheMisses: rs.cacheMisses,
+	}
+
+	for _, v := range violations {
+		if _, ok := rs.rules[v.Rule]; ok {
+			rs.rules[v.Rule].Violations++
+		}
+	}
+	for rule, n := range suppressed {
+		result.Suppressed += n
+		rs.ruleStats(rule).Suppressed += n
+	}
+	for name, stats := range rs.rules {
+		result.Rules[name] = *stats
+	}
+	for pkg, d := range rs.typeCheck {
+		result.TypeCheck[pkg] = d
+	}
+
+	pkgs := make([]string, 0, len(rs.loadErrors))
+	for pkg := range rs.loadErrors {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		result.LoadErrors = append(result.LoadErrors, PackageError{Package: pkg, Errors: rs.loadErrors[pkg]})
+	}
+
+	return result
+}
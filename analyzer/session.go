@@ -0,0 +1,267 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// sessionLoadMode loads the packages of a directory. Their dependencies
+// are listed with their export data, for those the Session doesn't have
+// already.
+const sessionLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedSyntax | packages.NeedImports | packages.NeedExportFile | packages.NeedTypesSizes | packages.NeedModule
+
+// Session analyzes packages again and again, as an editor does, keeping
+// the packages it loaded and type-checked between runs. Only the packages
+// invalidated since, and those importing them, are loaded again.
+//
+// A Session analyzes in the analyzer's first build configuration and
+// doesn't use its cache.
+type Session struct {
+	a *Analyzer
+
+	mu   sync.Mutex
+	fset *token.FileSet
+
+	// pkgs holds the type-checked packages by ID. It includes the
+	// dependencies of every package in it.
+	pkgs map[string]*packages.Package
+
+	// dirs holds the packages loaded from each directory, test variants
+	// included
+	dirs map[string][]*packages.Package
+
+	// importers maps package IDs to the IDs of the packages importing them
+	importers map[string]map[string]bool
+}
+
+// NewSession creates a Session analyzing with a
+func NewSession(a *Analyzer) *Session {
+	s := &Session{a: a}
+	s.Reset()
+	return s
+}
+
+// Reset drops all loaded packages, e.g. after go.mod changed
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fset = token.NewFileSet()
+	s.pkgs = make(map[string]*packages.Package)
+	s.dirs = make(map[string][]*packages.Package)
+	s.importers = make(map[string]map[string]bool)
+}
+
+// Invalidate drops the packages in the directories of files that changed,
+// were added or were removed, and the packages importing them, directly or
+// not
+func (s *Session) Invalidate(filenames ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirs := make(map[string]bool)
+	for _, filename := range filenames {
+		if abs, err := filepath.Abs(filename); err == nil {
+			dirs[filepath.Dir(abs)] = true
+		}
+	}
+
+	var ids []string
+	for id, pkg := range s.pkgs {
+		if dirs[packageDir(pkg)] {
+			ids = append(ids, id)
+		}
+	}
+	for dir := range dirs {
+		s.dropDir(dir)
+	}
+	for _, id := range ids {
+		s.drop(id)
+	}
+}
+
+// drop removes a package and its importers
+func (s *Session) drop(id string) {
+	pkg, ok := s.pkgs[id]
+	if !ok {
+		return
+	}
+	delete(s.pkgs, id)
+	s.dropDir(packageDir(pkg))
+
+	importers := s.importers[id]
+	delete(s.importers, id)
+	for importer := range importers {
+		s.drop(importer)
+	}
+}
+
+// dropDir removes the packages loaded from dir
+func (s *Session) dropDir(dir string) {
+	pkgs, ok := s.dirs[dir]
+	if !ok {
+		return
+	}
+	delete(s.dirs, dir)
+	for _, pkg := range pkgs {
+		s.drop(pkg.ID)
+	}
+}
+
+// AnalyzeFiles analyzes the packages of the directories of filenames, with
+// their test variants, and returns the violations in all their files
+func (s *Session) AnalyzeFiles(ctx context.Context, filenames []string) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, filename := range filenames {
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", filename, err)
+		}
+		if dir := filepath.Dir(abs); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	rs := newRunState()
+	loaded := make(map[string][]*packages.Package, len(dirs))
+	var pkgs []*packages.Package
+	for _, dir := range dirs {
+		dirPkgs, ok := s.dirs[dir]
+		if !ok {
+			var err error
+			if dirPkgs, err = s.load(ctx, dir, rs); err != nil {
+				return nil, err
+			}
+		}
+		loaded[dir] = dirPkgs
+		pkgs = append(pkgs, dirPkgs...)
+	}
+
+	if err := checkTypes(ctx, pkgs, s.a.workers(), rs); err != nil {
+		return nil, fmt.Errorf("analysis canceled: %w", err)
+	}
+	for dir, dirPkgs := range loaded {
+		s.dirs[dir] = dirPkgs
+		s.store(dirPkgs)
+	}
+
+	violations, err := s.a.analyzeLoaded(ctx, pkgs, nil, rs)
+	if err != nil {
+		return nil, err
+	}
+	return s.a.finish(violations, rs), nil
+}
+
+// load loads the packages of dir and links them to the packages of the
+// session
+func (s *Session) load(ctx context.Context, dir string, rs *runState) ([]*packages.Package, error) {
+	var build BuildConfig
+	if len(s.a.builds) > 0 {
+		build = s.a.builds[0]
+	}
+	cfg := &packages.Config{
+		Context:    ctx,
+		Mode:       sessionLoadMode,
+		Dir:        dir,
+		Tests:      true,
+		Fset:       s.fset,
+		BuildFlags: build.buildFlags(),
+		Env:        build.env(s.a.env),
+	}
+
+	start := time.Now()
+	pkgs, err := packages.Load(cfg, ".")
+	if err == nil {
+		s.link(pkgs)
+	}
+	rs.loaded(time.Since(start))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("analysis canceled: %w", ctxErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages in %s: %w", dir, err)
+	}
+	return pkgs, nil
+}
+
+// link replaces the loaded packages the session has already, and the
+// imports of the others, by the session's packages, so that all share the
+// same types
+func (s *Session) link(pkgs []*packages.Package) {
+	roots := make(map[string]*packages.Package, len(pkgs))
+	for i, pkg := range pkgs {
+		if known, ok := s.pkgs[pkg.ID]; ok {
+			if known.TypesInfo != nil {
+				pkgs[i] = known
+			} else {
+				// Read from export data as a dependency, without the
+				// syntax the rules need
+				s.drop(pkg.ID)
+			}
+		}
+		roots[pkgs[i].ID] = pkgs[i]
+	}
+
+	visited := make(map[*packages.Package]bool)
+	var visit func(pkg *packages.Package)
+	visit = func(pkg *packages.Package) {
+		if visited[pkg] || s.pkgs[pkg.ID] == pkg {
+			return
+		}
+		visited[pkg] = true
+		for path, imp := range pkg.Imports {
+			if known, ok := s.pkgs[imp.ID]; ok {
+				pkg.Imports[path] = known
+			} else if root, ok := roots[imp.ID]; ok {
+				pkg.Imports[path] = root
+			} else {
+				visit(imp)
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+}
+
+// store adds type-checked packages and their dependencies to the session
+func (s *Session) store(pkgs []*packages.Package) {
+	var visit func(pkg *packages.Package)
+	visit = func(pkg *packages.Package) {
+		if _, ok := s.pkgs[pkg.ID]; ok {
+			return
+		}
+		s.pkgs[pkg.ID] = pkg
+		for _, imp := range pkg.Imports {
+			if s.importers[imp.ID] == nil {
+				s.importers[imp.ID] = make(map[string]bool)
+			}
+			s.importers[imp.ID][pkg.ID] = true
+			visit(imp)
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+}
+
+// packageDir returns the directory of pkg's files, or "" without files
+func packageDir(pkg *packages.Package) string {
+	if len(pkg.GoFiles) == 0 {
+		return ""
+	}
+	return filepath.Dir(pkg.GoFiles[0])
+}
@@ -0,0 +1,87 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Arneball/goasted/analyzer"
+	"github.com/Arneball/goasted/rules"
+)
+
+func TestSession_ReloadsInvalidatedPackages(t *testing.T) {
+	dir := writeModule(t)
+	store := filepath.Join(dir, "store", "store.go")
+	app := filepath.Join(dir, "app", "app.go")
+	if err := os.MkdirAll(filepath.Dir(app), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(app, []byte("package app\n\nimport \"example.com/api/store\"\n\nvar Load = store.Load\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := analyzer.NewSession(analyzer.New(rules.DefaultRegistry()))
+	analyze := func(filename string) *analyzer.Result {
+		t.Helper()
+		result, err := s.AnalyzeFiles(context.Background(), []string{filename})
+		if err != nil {
+			t.Fatalf("AnalyzeFiles failed: %v", err)
+		}
+		return result
+	}
+	checked := func(result *analyzer.Result) []string {
+		var paths []string
+		for path := range result.TypeCheck {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	first := analyze(store)
+	if len(first.Violations) != 1 || first.Violations[0].File != store {
+		t.Fatalf("Expected 1 violation in store.go, got %+v", first.Violations)
+	}
+	if got := checked(first); len(got) != 2 || got[0] != "example.com/api/store" {
+		t.Errorf("Expected only store and its test variant to be type-checked, got %v", got)
+	}
+
+	// Nothing changed: nothing is loaded or type-checked again
+	second := analyze(store)
+	if len(second.Violations) != 1 {
+		t.Errorf("Expected 1 violation again, got %+v", second.Violations)
+	}
+	if second.Load != 0 || len(second.TypeCheck) != 0 {
+		t.Errorf("Expected no load, got %s and type-checks of %v", second.Load, checked(second))
+	}
+
+	// A new package reuses the packages it imports
+	third := analyze(app)
+	if got := checked(third); len(got) != 1 || got[0] != "example.com/api/app" {
+		t.Errorf("Expected only app to be type-checked, got %v", got)
+	}
+
+	// Editing store reloads it and its importers, not its dependencies
+	if err := os.WriteFile(store, []byte("package store\n\nimport \"database/sql\"\n\nfunc Load(db *sql.DB) {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.Invalidate(store)
+	fourth := analyze(app)
+	if fourth.Load == 0 {
+		t.Error("Expected app to be loaded again")
+	}
+	if got := checked(fourth); len(got) != 1 || got[0] != "example.com/api/app" {
+		t.Errorf("Expected only app to be type-checked, store coming from export data, got %v", got)
+	}
+
+	// Analyzing store itself type-checks it from source
+	fifth := analyze(store)
+	if _, ok := fifth.TypeCheck["example.com/api/store"]; !ok {
+		t.Errorf("Expected store to be type-checked, got %v", checked(fifth))
+	}
+	if len(fifth.Violations) != 0 {
+		t.Errorf("Expected no violations after the fix, got %+v", fifth.Violations)
+	}
+}
@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"go/types"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// ssaProgram builds the SSA form of the loaded packages on first use, so runs
+// without SSA rules don't pay for it
+type ssaProgram struct {
+	pkgs []*packages.Package
+
+	once     sync.Once
+	prog     *ssa.Program
+	packages map[*packages.Package]*ssa.Package
+}
+
+// newSSAProgram prepares lazy SSA construction for pkgs
+func newSSAProgram(pkgs []*packages.Package) *ssaProgram {
+	return &ssaProgram{pkgs: pkgs}
+}
+
+// context returns the SSA context for pkg, building the program on first
+// use and the package's function bodies on each package's first use
+func (p *ssaProgram) context(pkg *packages.Package) *rules.SSAContext {
+	p.once.Do(func() {
+		prog, ssaPkgs := ssautil.Packages(p.pkgs, ssa.InstantiateGenerics)
+		p.prog = prog
+		p.packages = make(map[*packages.Package]*ssa.Package, len(p.pkgs))
+		for i, ssaPkg := range ssaPkgs {
+			if ssaPkg != nil {
+				p.packages[p.pkgs[i]] = ssaPkg
+			}
+		}
+	})
+
+	ssaPkg, ok := p.packages[pkg]
+	if !ok {
+		return nil
+	}
+
+	// Build is safe to call concurrently and only builds once
+	ssaPkg.Build()
+
+	return &rules.SSAContext{
+		FileSet:   pkg.Fset,
+		Program:   p.prog,
+		Package:   ssaPkg,
+		Functions: sourceFunctions(p.prog, ssaPkg),
+	}
+}
+
+// sourceFunctions returns the functions, methods and function literals
+// declared in pkg
+func sourceFunctions(prog *ssa.Program, pkg *ssa.Package) []*ssa.Function {
+	var funcs []*ssa.Function
+	seen := make(map[*ssa.Function]bool)
+
+	var add func(fn *ssa.Function)
+	add = func(fn *ssa.Function) {
+		if fn == nil || seen[fn] || fn.Synthetic != "" || fn.Pkg != pkg {
+			return
+		}
+		seen[fn] = true
+		funcs = append(funcs, fn)
+		for _, anon := range fn.AnonFuncs {
+			add(anon)
+		}
+	}
+
+	for _, member := range pkg.Members {
+		switch m := member.(type) {
+		case *ssa.Function:
+			add(m)
+		case *ssa.Type:
+			named, ok := m.Type().(*types.Named)
+			if !ok || types.IsInterface(named) {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				add(prog.FuncValue(named.Method(i)))
+			}
+		}
+	}
+
+	// Members is a map, so sort for a stable order
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].Pos() < funcs[j].Pos()
+	})
+
+	return funcs
+}
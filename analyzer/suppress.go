@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+const (
+	// ignoreDirective suppresses violations on its own line and the next one
+	ignoreDirective = "//goasted:ignore"
+
+	// fileIgnoreDirective suppresses violations anywhere in the file
+	fileIgnoreDirective = "//goasted:file-ignore"
+
+	// InvalidIgnoreRule is the rule name reported for malformed directives
+	InvalidIgnoreRule = "invalid-ignore"
+
+	// UnusedIgnoreRule is the rule name reported for directives that no
+	// longer suppress anything
+	UnusedIgnoreRule = "unused-ignore"
+)
+
+// directive is a single parsed suppression comment
+type directive struct {
+	pos       token.Position
+	rules     []string
+	fileLevel bool
+	used      map[string]bool
+}
+
+// covers reports whether the directive suppresses v
+func (d *directive) covers(v rules.Violation) bool {
+	if !d.fileLevel && v.Line != d.pos.Line && v.Line != d.pos.Line+1 {
+		return false
+	}
+	for _, rule := range d.rules {
+		if rule == v.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSuppressions holds the directives of one file, the violations for its
+// malformed directives and the rules that ran on it
+type fileSuppressions struct {
+	directives  []*directive
+	invalid     []rules.Violation
+	activeRules map[string]bool
+}
+
+// suppressions collects suppression directives during a run and filters the
+// resulting violations
+type suppressions struct {
+	mu    sync.Mutex
+	files map[string]*fileSuppressions
+
+	// byRule counts the suppressed violations per rule
+	byRule map[string]int
+}
+
+// newSuppressions creates an empty suppression index
+func newSuppressions() *suppressions {
+	return &suppressions{files: make(map[string]*fileSuppressions), byRule: make(map[string]int)}
+}
+
+// record parses the directives of the file in ctx and notes which rules ran
+// on it. Directives naming a rule that known rejects are invalid; a nil known
+// accepts every name. A file seen more than once (e.g. in test package
+// variants) is only parsed the first time.
+func (s *suppressions) record(ctx *rules.Context, active []rules.Checker, known func(string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fs, ok := s.files[ctx.Filename]
+	if !ok {
+		fs = &fileSuppressions{activeRules: make(map[string]bool)}
+		s.files[ctx.Filename] = fs
+		parseDirectives(ctx, fs, known)
+	}
+	for _, rule := range active {
+		fs.activeRules[rule.Name()] = true
+	}
+}
+
+// parseDirectives extracts the directives of a file
+func parseDirectives(ctx *rules.Context, fs *fileSuppressions, known func(string) bool) {
+	for _, group := range ctx.File.Comments {
+		for _, comment := range group.List {
+			var body string
+			var fileLevel bool
+			switch {
+			case strings.HasPrefix(comment.Text, fileIgnoreDirective):
+				body = strings.TrimPrefix(comment.Text, fileIgnoreDirective)
+				fileLevel = true
+			case strings.HasPrefix(comment.Text, ignoreDirective):
+				body = strings.TrimPrefix(comment.Text, ignoreDirective)
+			default:
+				continue
+			}
+
+			pos := ctx.FileSet.Position(comment.Pos())
+			if body != "" && body[0] != ' ' && body[0] != '\t' {
+				// Some other directive such as //goasted:ignoreme
+				continue
+			}
+
+			ruleList, reason, _ := strings.Cut(body, " -- ")
+			var names []string
+			for _, name := range strings.Split(ruleList, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					names = append(names, name)
+				}
+			}
+
+			if len(names) == 0 {
+				fs.invalid = append(fs.invalid, invalidDirective(ctx.Filename, pos, "suppression directive must name at least one rule: //goasted:ignore <rule>[,<rule>] -- <reason>"))
+				continue
+			}
+			if strings.TrimSpace(reason) == "" {
+				fs.invalid = append(fs.invalid, invalidDirective(ctx.Filename, pos, "suppression directive requires a reason: //goasted:ignore "+strings.Join(names, ",")+" -- <reason>"))
+				continue
+			}
+			if unknown := unknownRule(names, known); unknown != "" {
+				fs.invalid = append(fs.invalid, invalidDirective(ctx.Filename, pos, fmt.Sprintf("suppression directive names unknown rule %q", unknown)))
+				continue
+			}
+
+			fs.directives = append(fs.directives, &directive{
+				pos:       pos,
+				rules:     names,
+				fileLevel: fileLevel,
+				used:      make(map[string]bool),
+			})
+		}
+	}
+}
+
+// unknownRule returns the first of names that known rejects, or ""
+func unknownRule(names []string, known func(string) bool) string {
+	if known == nil {
+		return ""
+	}
+	for _, name := range names {
+		if !known(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// invalidDirective creates the violation reported for a malformed directive
+func invalidDirective(filename string, pos token.Position, message string) rules.Violation {
+	return rules.Violation{
+		File:     filename,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Rule:     InvalidIgnoreRule,
+		Message:  message,
+		Severity: rules.SeverityError,
+	}
+}
+
+// filter removes suppressed violations and appends violations for invalid
+// directives and, if reportUnused is set, for directives that suppressed
+// nothing. It also returns the number of suppressed violations.
+func (s *suppressions) filter(violations []rules.Violation, reportUnused bool) ([]rules.Violation, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []rules.Violation
+	suppressed := 0
+	for _, v := range violations {
+		if s.suppress(v) {
+			suppressed++
+			s.byRule[v.Rule]++
+			continue
+		}
+		kept = append(kept, v)
+	}
+
+	for _, filename := range s.filenames() {
+		kept = append(kept, s.files[filename].invalid...)
+	}
+
+	if reportUnused {
+		kept = append(kept, s.unused()...)
+	}
+
+	return kept, suppressed
+}
+
+// suppress reports whether v is covered by a directive, marking it used
+func (s *suppressions) suppress(v rules.Violation) bool {
+	fs, ok := s.files[v.File]
+	if !ok {
+		return false
+	}
+	for _, d := range fs.directives {
+		if d.covers(v) {
+			d.used[v.Rule] = true
+			return true
+		}
+	}
+	return false
+}
+
+// unused returns a violation for every rule named in a directive that did not
+// suppress anything, as long as that rule actually ran on the file
+func (s *suppressions) unused() []rules.Violation {
+	var violations []rules.Violation
+	for _, filename := range s.filenames() {
+		fs := s.files[filename]
+		for _, d := range fs.directives {
+			for _, rule := range d.rules {
+				if d.used[rule] || !fs.activeRules[rule] {
+					continue
+				}
+				violations = append(violations, rules.Violation{
+					File:     filename,
+					Line:     d.pos.Line,
+					Column:   d.pos.Column,
+					Rule:     UnusedIgnoreRule,
+					Message:  "Suppression of " + rule + " no longer matches any violation",
+					Severity: rules.SeverityWarning,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// filenames returns the recorded files in order
+func (s *suppressions) filenames() []string {
+	filenames := make([]string, 0, len(s.files))
+	for filename := range s.files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
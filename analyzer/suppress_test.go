@@ -0,0 +1,145 @@
+package analyzer
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func recordSource(t *testing.T, sup *suppressions, filename, src string) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	sup.record(&rules.Context{FileSet: fset, File: file, Filename: filename}, rules.DefaultRegistry().GetCheckers(), nil)
+}
+
+func violationAt(line int, rule string) rules.Violation {
+	return rules.Violation{File: "main.go", Line: line, Column: 1, Rule: rule, Message: "bad"}
+}
+
+func TestSuppressions_LineAndLineAbove(t *testing.T) {
+	src := `package main
+
+//goasted:ignore sql-context-required -- legacy adapter behind third-party interface
+func a() {}
+
+func b() {} //goasted:ignore gokit-usage,testify-usage -- vendored shim
+`
+	sup := newSuppressions()
+	recordSource(t, sup, "main.go", src)
+
+	violations, suppressed := sup.filter([]rules.Violation{
+		violationAt(3, "sql-context-required"),
+		violationAt(4, "sql-context-required"),
+		violationAt(5, "sql-context-required"),
+		violationAt(6, "gokit-usage"),
+		violationAt(6, "sql-context-required"),
+	}, false)
+
+	if suppressed != 3 {
+		t.Errorf("Expected 3 suppressed violations, got %d", suppressed)
+	}
+	if len(violations) != 2 {
+		t.Errorf("Expected 2 remaining violations, got %d", len(violations))
+	}
+}
+
+func TestSuppressions_FileLevel(t *testing.T) {
+	src := `//goasted:file-ignore testify-usage -- migration tracked separately
+package main
+`
+	sup := newSuppressions()
+	recordSource(t, sup, "main.go", src)
+
+	violations, _ := sup.filter([]rules.Violation{
+		violationAt(40, "testify-usage"),
+		violationAt(41, "gokit-usage"),
+	}, false)
+
+	if len(violations) != 1 || violations[0].Rule != "gokit-usage" {
+		t.Errorf("Expected only the gokit-usage violation to remain, got %v", violations)
+	}
+}
+
+func TestSuppressions_RequiresReason(t *testing.T) {
+	src := `package main
+
+//goasted:ignore sql-context-required
+func a() {}
+`
+	sup := newSuppressions()
+	recordSource(t, sup, "main.go", src)
+
+	violations, suppressed := sup.filter([]rules.Violation{violationAt(4, "sql-context-required")}, false)
+
+	if suppressed != 0 {
+		t.Errorf("Expected directive without reason to suppress nothing, got %d", suppressed)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("Expected original violation plus invalid directive, got %d", len(violations))
+	}
+	if violations[1].Rule != InvalidIgnoreRule || !strings.Contains(violations[1].Message, "requires a reason") {
+		t.Errorf("Unexpected invalid directive violation: %+v", violations[1])
+	}
+}
+
+func TestSuppressions_UnknownRule(t *testing.T) {
+	src := `package main
+
+//goasted:ignore sql-context-requried -- typo
+func a() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	registry := rules.DefaultRegistry()
+	sup := newSuppressions()
+	sup.record(&rules.Context{FileSet: fset, File: file, Filename: "main.go"}, registry.GetCheckers(), func(name string) bool {
+		return registry.GetChecker(name) != nil
+	})
+
+	violations, suppressed := sup.filter([]rules.Violation{violationAt(4, "sql-context-required")}, false)
+
+	if suppressed != 0 {
+		t.Errorf("Expected a directive naming an unknown rule to suppress nothing, got %d", suppressed)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("Expected original violation plus invalid directive, got %d", len(violations))
+	}
+	if violations[1].Rule != InvalidIgnoreRule || !strings.Contains(violations[1].Message, `unknown rule "sql-context-requried"`) {
+		t.Errorf("Unexpected invalid directive violation: %+v", violations[1])
+	}
+}
+
+func TestSuppressions_ReportsUnused(t *testing.T) {
+	src := `package main
+
+//goasted:ignore sql-context-required,no-longer-run -- legacy
+func a() {}
+`
+	sup := newSuppressions()
+	recordSource(t, sup, "main.go", src)
+
+	violations, _ := sup.filter(nil, false)
+	if len(violations) != 0 {
+		t.Errorf("Expected no unused report unless requested, got %d", len(violations))
+	}
+
+	violations, _ = sup.filter(nil, true)
+	// no-longer-run did not run on the file, so only sql-context-required is reported
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 unused directive, got %d", len(violations))
+	}
+	if violations[0].Rule != UnusedIgnoreRule || violations[0].Line != 3 {
+		t.Errorf("Unexpected unused directive violation: %+v", violations[0])
+	}
+}
@@ -0,0 +1,8 @@
+package degraded
+
+import "example.com/doesnotexist"
+
+// Broken depends on a module that can't be found
+func Broken() {
+	doesnotexist.Call()
+}
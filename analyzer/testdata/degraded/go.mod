@@ -0,0 +1,3 @@
+module degraded
+
+go 1.25
@@ -0,0 +1,9 @@
+package lib
+
+import "database/sql"
+
+// Lib lives in a package of the outer module
+func Lib(db *sql.DB) error {
+	_, err := db.Exec("SELECT 1")
+	return err
+}
@@ -0,0 +1,9 @@
+package nested
+
+import "database/sql"
+
+// Root lives in the outer module
+func Root(db *sql.DB) error {
+	_, err := db.Exec("SELECT 1")
+	return err
+}
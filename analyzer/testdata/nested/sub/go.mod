@@ -0,0 +1,3 @@
+module nested/sub
+
+go 1.25
@@ -0,0 +1,9 @@
+package sub
+
+import "database/sql"
+
+// Sub lives in a nested module
+func Sub(db *sql.DB) error {
+	_, err := db.Exec("SELECT 1")
+	return err
+}
@@ -0,0 +1,9 @@
+package platforms
+
+import "database/sql"
+
+// Common is built everywhere
+func Common(db *sql.DB) error {
+	_, err := db.Query("SELECT 1")
+	return err
+}
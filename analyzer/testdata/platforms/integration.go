@@ -0,0 +1,11 @@
+//go:build integration
+
+package platforms
+
+import "database/sql"
+
+// Integration is only built with the integration tag
+func Integration(db *sql.DB) error {
+	_, err := db.Exec("SELECT 1")
+	return err
+}
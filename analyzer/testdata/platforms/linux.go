@@ -0,0 +1,11 @@
+//go:build linux
+
+package platforms
+
+import "database/sql"
+
+// Linux is only built on linux
+func Linux(db *sql.DB) error {
+	_, err := db.Exec("SELECT 1")
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package platforms
+
+import "database/sql"
+
+// Windows is only built on windows
+func Windows(db *sql.DB) error {
+	_, err := db.Exec("SELECT 1")
+	return err
+}
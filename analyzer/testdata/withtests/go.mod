@@ -0,0 +1,3 @@
+module withtests
+
+go 1.25
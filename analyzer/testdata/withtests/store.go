@@ -0,0 +1,9 @@
+package withtests
+
+import "database/sql"
+
+// Count queries without a context
+func Count(db *sql.DB) error {
+	_, err := db.Query("SELECT COUNT(*) FROM users")
+	return err
+}
@@ -0,0 +1,13 @@
+package withtests
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	var db *sql.DB
+	if db != nil {
+		_, _ = db.Exec("DELETE FROM users")
+	}
+}
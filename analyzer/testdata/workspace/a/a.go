@@ -0,0 +1,8 @@
+package a
+
+import "database/sql"
+
+// Store holds a database connection
+type Store struct {
+	Conn *sql.DB
+}
@@ -0,0 +1,9 @@
+package b
+
+import "example.com/a"
+
+// Ping queries through a type declared in another workspace module
+func Ping(s *a.Store) error {
+	_, err := s.Conn.Exec("SELECT 1")
+	return err
+}
@@ -0,0 +1,347 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/types"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// LoadErrorRule is the rule name reported for packages that could not be
+// loaded cleanly and were analyzed with partial type information
+const LoadErrorRule = "load-error"
+
+// packageImporter resolves imports from the dependencies packages.Load
+// already type-checked, and falls back to type-checking from source, which
+// finds packages in the module cache
+type packageImporter struct {
+	imports map[string]*packages.Package
+	source  types.ImporterFrom
+}
+
+func (i *packageImporter) Import(path string) (*types.Package, error) {
+	return i.ImportFrom(path, "", 0)
+}
+
+func (i *packageImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if imp, ok := i.imports[path]; ok && imp.Types != nil && !imp.IllTyped && len(imp.Errors) == 0 {
+		return imp.Types, nil
+	}
+	return i.source.ImportFrom(path, dir, mode)
+}
+
+// recheckPackage type-checks a package that failed to load cleanly as a
+// whole, tolerating errors, so that rules still see types declared in the
+// package's other files. Files that don't parse contribute what the parser
+// could recover. The returned copy of pkg carries the new syntax and type
+// information.
+func recheckPackage(pkg *packages.Package) *packages.Package {
+	syntax := make(map[string]*ast.File, len(pkg.Syntax))
+	for _, file := range pkg.Syntax {
+		syntax[pkg.Fset.File(file.Pos()).Name()] = file
+	}
+
+	rechecked := *pkg
+	rechecked.Syntax = nil
+	rechecked.GoFiles = nil
+	for _, filename := range pkg.GoFiles {
+		file, ok := syntax[filename]
+		if !ok {
+			// ParseFile returns a partial AST alongside syntax errors
+			file, _ = parser.ParseFile(pkg.Fset, filename, nil, parser.ParseComments)
+			if file == nil {
+				continue
+			}
+		}
+		rechecked.Syntax = append(rechecked.Syntax, file)
+		rechecked.GoFiles = append(rechecked.GoFiles, filename)
+	}
+
+	conf := types.Config{
+		Importer: &packageImporter{
+			imports: pkg.Imports,
+			source:  importer.ForCompiler(pkg.Fset, "source", nil).(types.ImporterFrom),
+		},
+		Error: func(err error) {
+			// Keep going: partial information beats none
+		},
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Instances:  make(map[*ast.Ident]types.Instance),
+	}
+	rechecked.Types, _ = conf.Check(pkg.PkgPath, pkg.Fset, rechecked.Syntax, info)
+	rechecked.TypesInfo = info
+
+	return &rechecked
+}
+
+// loadErrorViolation reports that pkg was analyzed with partial type
+// information, positioned at its first error if possible
+func loadErrorViolation(pkg *packages.Package) (rules.Violation, bool) {
+	if len(pkg.Errors) == 0 {
+		return rules.Violation{}, false
+	}
+	first := pkg.Errors[0]
+
+	v := rules.Violation{
+		Rule:     LoadErrorRule,
+		Severity: rules.SeverityWarning,
+	}
+	if file, line, column, ok := parseErrorPos(first.Pos); ok {
+		v.File, v.Line, v.Column = file, line, column
+	} else if len(pkg.GoFiles) > 0 {
+		v.File = pkg.GoFiles[0]
+	} else {
+		return rules.Violation{}, false
+	}
+
+	v.Message = fmt.Sprintf("package %s was analyzed with partial type information: %s", pkg.PkgPath, first.Msg)
+	if more := len(pkg.Errors) - 1; more > 0 {
+		v.Message += fmt.Sprintf(" (and %d more error(s))", more)
+	}
+	return v, true
+}
+
+// parseErrorPos parses a "file:line:col" or "file:line" error position
+func parseErrorPos(pos string) (string, int, int, bool) {
+	if pos == "" || pos == "-" {
+		return "", 0, 0, false
+	}
+
+	rest, last, ok := cutLast(pos)
+	if !ok {
+		return "", 0, 0, false
+	}
+	n, err := strconv.Atoi(last)
+	if err != nil {
+		return "", 0, 0, false
+	}
+
+	file, middle, ok := cutLast(rest)
+	if ok {
+		if line, err := strconv.Atoi(middle); err == nil {
+			return file, line, n, true
+		}
+	}
+	return rest, n, 0, true
+}
+
+// cutLast splits s around its last colon
+func cutLast(s string) (string, string, bool) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+// checkTypes type-checks pkgs from the syntax packages.Load parsed, in
+// dependency order with up to workers packages at a time. Their
+// dependencies get their types from export data, or from source when they
+// have none, e.g. because they don't compile. Type errors are added to
+// each package's Errors, as packages.Load would, and the type-check time
+// of each package of pkgs is recorded in rs. Packages that already have
+// types are left as they are.
+func checkTypes(ctx context.Context, pkgs []*packages.Package, workers int, rs *runState) error {
+	roots := make(map[*packages.Package]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		roots[pkg] = true
+	}
+
+	var mu, exportMu sync.Mutex
+	done := make(map[*packages.Package]chan struct{})
+	sem := make(chan struct{}, workers)
+
+	var check func(pkg *packages.Package) chan struct{}
+	check = func(pkg *packages.Package) chan struct{} {
+		mu.Lock()
+		ch, ok := done[pkg]
+		if !ok {
+			ch = make(chan struct{})
+			done[pkg] = ch
+		}
+		mu.Unlock()
+		if ok {
+			return ch
+		}
+
+		go func() {
+			defer close(ch)
+			if pkg.Types != nil {
+				// Checked by an earlier load of a Session
+				return
+			}
+			for _, imp := range pkg.Imports {
+				<-check(imp)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if !roots[pkg] {
+				// Reading export data may complete the packages it
+				// mentions, so only one package is read at a time
+				exportMu.Lock()
+				err := importPackageTypes(pkg)
+				exportMu.Unlock()
+				if err == nil {
+					return
+				}
+				parsePackage(pkg)
+			}
+
+			start := time.Now()
+			checkPackageTypes(pkg)
+			if roots[pkg] {
+				rs.typeChecked(pkg.PkgPath, time.Since(start))
+			}
+		}()
+		return ch
+	}
+
+	for _, pkg := range pkgs {
+		<-check(pkg)
+	}
+	return ctx.Err()
+}
+
+// importPackageTypes reads the types of a dependency from its export data.
+// The packages it imports, directly or not, have their types already.
+func importPackageTypes(pkg *packages.Package) error {
+	if pkg.PkgPath == "unsafe" {
+		pkg.Types = types.Unsafe
+		return nil
+	}
+	if pkg.ExportFile == "" {
+		return fmt.Errorf("no export data for %s", pkg.PkgPath)
+	}
+
+	f, err := os.Open(pkg.ExportFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	r, err := gcexportdata.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read export data of %s: %w", pkg.PkgPath, err)
+	}
+
+	// Export data names packages by path, which test variants share, so
+	// the reader only sees the packages pkg depends on
+	view := make(map[string]*types.Package)
+	packages.Visit([]*packages.Package{pkg}, func(imp *packages.Package) bool {
+		if imp == pkg {
+			return true
+		}
+		if _, ok := view[imp.PkgPath]; ok || imp.Types == nil {
+			return false
+		}
+		view[imp.PkgPath] = imp.Types
+		return true
+	}, nil)
+
+	tpkg, err := gcexportdata.Read(r, pkg.Fset, view, pkg.PkgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read export data of %s: %w", pkg.PkgPath, err)
+	}
+	pkg.Types = tpkg
+	return nil
+}
+
+// parsePackage parses the files of a dependency without export data, which
+// packages.Load only lists
+func parsePackage(pkg *packages.Package) {
+	pkg.Syntax = make([]*ast.File, 0, len(pkg.CompiledGoFiles))
+	for _, filename := range pkg.CompiledGoFiles {
+		file, err := parser.ParseFile(pkg.Fset, filename, nil, parser.AllErrors|parser.ParseComments)
+		if file != nil {
+			pkg.Syntax = append(pkg.Syntax, file)
+		}
+		if err != nil {
+			pkg.Errors = append(pkg.Errors, packages.Error{Pos: "-", Msg: err.Error(), Kind: packages.ParseError})
+		}
+	}
+}
+
+// checkPackageTypes type-checks one package whose imports are checked
+func checkPackageTypes(pkg *packages.Package) {
+	if pkg.PkgPath == "unsafe" {
+		pkg.Types = types.Unsafe
+		pkg.TypesInfo = new(types.Info)
+		return
+	}
+
+	pkg.TypesInfo = &types.Info{
+		Types:        make(map[ast.Expr]types.TypeAndValue),
+		Defs:         make(map[*ast.Ident]types.Object),
+		Uses:         make(map[*ast.Ident]types.Object),
+		Implicits:    make(map[ast.Node]types.Object),
+		Instances:    make(map[*ast.Ident]types.Instance),
+		Scopes:       make(map[ast.Node]*types.Scope),
+		Selections:   make(map[*ast.SelectorExpr]*types.Selection),
+		FileVersions: make(map[*ast.File]string),
+	}
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			imp, ok := pkg.Imports[path]
+			if !ok || imp.Types == nil {
+				return nil, fmt.Errorf("could not import %s", path)
+			}
+			return imp.Types, nil
+		}),
+		Error: func(err error) {
+			pkg.Errors = append(pkg.Errors, typeError(err))
+		},
+		Sizes: pkg.TypesSizes,
+	}
+	if pkg.Module != nil && pkg.Module.GoVersion != "" {
+		conf.GoVersion = "go" + pkg.Module.GoVersion
+	}
+
+	pkg.Types = types.NewPackage(pkg.PkgPath, pkg.Name)
+	_ = types.NewChecker(&conf, pkg.Fset, pkg.Types, pkg.TypesInfo).Files(pkg.Syntax)
+
+	pkg.IllTyped = len(pkg.Errors) > 0
+	for _, imp := range pkg.Imports {
+		pkg.IllTyped = pkg.IllTyped || imp.IllTyped
+	}
+}
+
+// typeError converts a type-checker error the way packages.Load does
+func typeError(err error) packages.Error {
+	if terr, ok := err.(types.Error); ok {
+		return packages.Error{Pos: terr.Fset.Position(terr.Pos).String(), Msg: terr.Msg, Kind: packages.TypeError}
+	}
+	return packages.Error{Pos: "-", Msg: err.Error(), Kind: packages.TypeError}
+}
+
+// importerFunc implements types.Importer with a function
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
@@ -0,0 +1,158 @@
+// Package baseline records known violations so that only new ones are
+// reported.
+//
+// Violations are matched by a fingerprint made of the rule, the file, the
+// enclosing function and the normalized source text of the flagged line.
+// Line numbers are deliberately left out so that entries survive unrelated
+// edits that shift code up or down.
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// Version is the current baseline file format version
+const Version = 1
+
+// DefaultFile is the default baseline file name
+const DefaultFile = ".goasted-baseline.json"
+
+// Fingerprint identifies a violation independently of its line number
+type Fingerprint struct {
+	Rule     string `json:"rule"`
+	File     string `json:"file"`
+	Function string `json:"function,omitempty"`
+	Text     string `json:"text"`
+}
+
+// Entry is a fingerprint together with the number of violations sharing it
+type Entry struct {
+	Fingerprint
+	Count int `json:"count"`
+}
+
+// Baseline is the on-disk baseline file
+type Baseline struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+
+	// dir is the directory the baseline file lives in. File paths in
+	// fingerprints are relative to it.
+	dir string
+}
+
+// New creates a baseline for the given violations. File paths are stored
+// relative to dir, normally the directory of the baseline file.
+func New(dir string, violations []rules.Violation) (*Baseline, error) {
+	b := &Baseline{Version: Version, dir: dir}
+	fp := newFingerprinter(dir)
+
+	counts := make(map[Fingerprint]int)
+	for _, v := range violations {
+		f, err := fp.fingerprint(v)
+		if err != nil {
+			return nil, err
+		}
+		counts[f]++
+	}
+
+	for f, count := range counts {
+		b.Entries = append(b.Entries, Entry{Fingerprint: f, Count: count})
+	}
+	sortEntries(b.Entries)
+
+	return b, nil
+}
+
+// Load reads a baseline file
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	b := &Baseline{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	if b.Version != Version {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s (expected %d)", b.Version, path, Version)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	b.dir = filepath.Dir(abs)
+
+	return b, nil
+}
+
+// Write writes the baseline to path
+func (b *Baseline) Write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return nil
+}
+
+// Filter removes the violations recorded in the baseline. It returns the new
+// violations and the stale entries, i.e. entries (or the part of their
+// count) that no longer match any violation and can be removed.
+func (b *Baseline) Filter(violations []rules.Violation) ([]rules.Violation, []Entry, error) {
+	remaining := make(map[Fingerprint]int, len(b.Entries))
+	for _, e := range b.Entries {
+		remaining[e.Fingerprint] += e.Count
+	}
+
+	fp := newFingerprinter(b.dir)
+	var kept []rules.Violation
+	for _, v := range violations {
+		f, err := fp.fingerprint(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if remaining[f] > 0 {
+			remaining[f]--
+			continue
+		}
+		kept = append(kept, v)
+	}
+
+	var stale []Entry
+	for f, count := range remaining {
+		if count > 0 {
+			stale = append(stale, Entry{Fingerprint: f, Count: count})
+		}
+	}
+	sortEntries(stale)
+
+	return kept, stale, nil
+}
+
+// sortEntries sorts entries by file, rule, function and text
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		if a.Function != b.Function {
+			return a.Function < b.Function
+		}
+		return a.Text < b.Text
+	})
+}
@@ -0,0 +1,143 @@
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+const testSource = `package main
+
+import "database/sql"
+
+type Repo struct{ db *sql.DB }
+
+func (r *Repo) Get() {
+	r.db.Exec("SELECT 1")
+	r.db.Exec("SELECT 1")
+}
+
+func Ping(db *sql.DB) {
+	db.Ping()
+}
+`
+
+func writeSource(t *testing.T, dir, src string) string {
+	t.Helper()
+
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	return file
+}
+
+func sqlViolation(file string, line int) rules.Violation {
+	return rules.Violation{File: file, Line: line, Column: 2, Rule: "sql-context-required", Message: "Use context"}
+}
+
+func TestNew_FingerprintsViolations(t *testing.T) {
+	dir := t.TempDir()
+	file := writeSource(t, dir, testSource)
+
+	b, err := New(dir, []rules.Violation{sqlViolation(file, 8), sqlViolation(file, 9), sqlViolation(file, 13)})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if len(b.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(b.Entries))
+	}
+	want := Entry{
+		Fingerprint: Fingerprint{Rule: "sql-context-required", File: "main.go", Function: "Ping", Text: "db.Ping()"},
+		Count:       1,
+	}
+	if b.Entries[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, b.Entries[0])
+	}
+	if b.Entries[1].Function != "Repo.Get" || b.Entries[1].Count != 2 {
+		t.Errorf("Expected Repo.Get entry with count 2, got %+v", b.Entries[1])
+	}
+}
+
+func TestFilter_SurvivesLineShifts(t *testing.T) {
+	dir := t.TempDir()
+	file := writeSource(t, dir, testSource)
+
+	b, err := New(dir, []rules.Violation{sqlViolation(file, 8), sqlViolation(file, 9), sqlViolation(file, 13)})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	path := filepath.Join(dir, DefaultFile)
+	if err := b.Write(path); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Shift everything down by two lines and reindent one call
+	writeSource(t, dir, "// Package main\n\n"+testSource)
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	kept, stale, err := loaded.Filter([]rules.Violation{sqlViolation(file, 10), sqlViolation(file, 11), sqlViolation(file, 15)})
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	if len(kept) != 0 {
+		t.Errorf("Expected all violations to match the baseline, got %d new", len(kept))
+	}
+	if len(stale) != 0 {
+		t.Errorf("Expected no stale entries, got %d", len(stale))
+	}
+}
+
+func TestFilter_ReportsNewAndStale(t *testing.T) {
+	dir := t.TempDir()
+	file := writeSource(t, dir, testSource)
+
+	b, err := New(dir, []rules.Violation{sqlViolation(file, 8), sqlViolation(file, 9)})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// One of the two Exec calls was fixed, and Ping is new
+	kept, stale, err := b.Filter([]rules.Violation{sqlViolation(file, 8), sqlViolation(file, 13)})
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	if len(kept) != 1 || kept[0].Line != 13 {
+		t.Errorf("Expected only the Ping violation to be new, got %v", kept)
+	}
+	if len(stale) != 1 || stale[0].Count != 1 || stale[0].Function != "Repo.Get" {
+		t.Errorf("Expected 1 stale Repo.Get entry, got %+v", stale)
+	}
+}
+
+func TestNew_ViolationWithoutFile(t *testing.T) {
+	dir := t.TempDir()
+	file := writeSource(t, dir, testSource)
+	internal := rules.Violation{Rule: "internal-error", Message: "rule panicked"}
+
+	b, err := New(dir, []rules.Violation{internal, sqlViolation(file, 13)})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if len(b.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(b.Entries))
+	}
+
+	kept, stale, err := b.Filter([]rules.Violation{internal})
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	if len(kept) != 0 {
+		t.Errorf("Expected the violation without a file to match the baseline, got %v", kept)
+	}
+	if len(stale) != 1 || stale[0].Function != "Ping" {
+		t.Errorf("Expected 1 stale Ping entry, got %+v", stale)
+	}
+}
@@ -0,0 +1,138 @@
+package baseline
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// funcRange is the line range of a top-level function declaration
+type funcRange struct {
+	name       string
+	start, end int
+}
+
+// sourceFile caches the lines and functions of a file
+type sourceFile struct {
+	lines []string
+	funcs []funcRange
+}
+
+// fingerprinter computes fingerprints, caching parsed files
+type fingerprinter struct {
+	dir   string
+	files map[string]*sourceFile
+}
+
+// newFingerprinter creates a fingerprinter storing paths relative to dir
+func newFingerprinter(dir string) *fingerprinter {
+	return &fingerprinter{dir: dir, files: make(map[string]*sourceFile)}
+}
+
+// fingerprint computes the fingerprint of a violation
+func (fp *fingerprinter) fingerprint(v rules.Violation) (Fingerprint, error) {
+	// Plugin and internal errors may not point at a file at all
+	if v.File == "" {
+		return Fingerprint{Rule: v.Rule}, nil
+	}
+
+	abs, err := filepath.Abs(v.File)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	rel := abs
+	if fp.dir != "" {
+		if r, err := filepath.Rel(fp.dir, abs); err == nil {
+			rel = r
+		}
+	}
+
+	src, err := fp.load(abs)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	f := Fingerprint{
+		Rule: v.Rule,
+		File: filepath.ToSlash(rel),
+	}
+	if v.Line >= 1 && v.Line <= len(src.lines) {
+		f.Text = normalize(src.lines[v.Line-1])
+	}
+	for _, fn := range src.funcs {
+		if v.Line >= fn.start && v.Line <= fn.end {
+			f.Function = fn.name
+			break
+		}
+	}
+
+	return f, nil
+}
+
+// load reads and parses a file, caching the result
+func (fp *fingerprinter) load(filename string) (*sourceFile, error) {
+	if src, ok := fp.files[filename]; ok {
+		return src, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	src := &sourceFile{lines: strings.Split(string(data), "\n")}
+
+	// Parse errors only cost us the function names
+	fset := token.NewFileSet()
+	if file, err := parser.ParseFile(fset, filename, data, parser.SkipObjectResolution); err == nil {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			src.funcs = append(src.funcs, funcRange{
+				name:  funcName(fn),
+				start: fset.Position(fn.Pos()).Line,
+				end:   fset.Position(fn.End()).Line,
+			})
+		}
+	}
+
+	fp.files[filename] = src
+	return src, nil
+}
+
+// funcName returns Name for functions and Recv.Name for methods
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	switch r := recv.(type) {
+	case *ast.IndexExpr:
+		recv = r.X
+	case *ast.IndexListExpr:
+		recv = r.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// normalize trims a source line and collapses runs of whitespace, so that
+// reindenting code doesn't invalidate the baseline
+func normalize(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Arneball/goasted/baseline"
+	"github.com/Arneball/goasted/rules"
+)
+
+// runBaseline implements "goasted baseline write"
+func runBaseline(args []string) int {
+	if len(args) == 0 || args[0] != "write" {
+		_, _ = fmt.Fprintln(os.Stderr, "Usage: goasted baseline write [-o file] [-path path] [-rules rules] [packages]")
+		return 2
+	}
+
+	var af analysisFlags
+	var output string
+
+	fs := flag.NewFlagSet("goasted baseline write", flag.ExitOnError)
+	af.register(fs)
+	fs.StringVar(&output, "o", baseline.DefaultFile, "Baseline file to write")
+	_ = fs.Parse(args[1:])
+	af.patterns = fs.Args()
+
+	violations, _, err := af.analyze()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+
+	abs, err := filepath.Abs(output)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+		return 1
+	}
+
+	b, err := baseline.New(filepath.Dir(abs), violations)
+	if err == nil {
+		err = b.Write(output)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+		return 1
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "Wrote %d violation(s) in %d entries to %s\n", len(violations), len(b.Entries), output)
+	return 0
+}
+
+// filterBaseline removes the violations recorded in the baseline file and
+// reports stale entries to w
+func filterBaseline(violations []rules.Violation, path string, w io.Writer) ([]rules.Violation, error) {
+	b, err := baseline.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	kept, stale, err := b.Filter(violations)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stale) > 0 {
+		_, _ = fmt.Fprintf(w, "%d stale baseline entries no longer match any violation; rerun \"goasted baseline write\" to drop them:\n", len(stale))
+		for _, e := range stale {
+			_, _ = fmt.Fprintf(w, "  %s: [%s] %s: %s (x%d)\n", e.File, e.Rule, e.Function, e.Text, e.Count)
+		}
+	}
+
+	return kept, nil
+}
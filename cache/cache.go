@@ -0,0 +1,248 @@
+// Package cache stores analysis results on disk so that unchanged packages
+// are not analyzed again.
+//
+// The cache is content-addressed: an entry is stored under a hash of
+// everything its value depends on, so entries never need to be invalidated.
+// A changed input produces a new key, and the stale entry is simply no
+// longer read. Nothing is evicted automatically: the cache grows until Clean
+// removes all entries.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempPrefix starts the names of the files entries are written to before
+// they are renamed into place. Files left by interrupted runs are removed
+// by Clean.
+const tempPrefix = "tmp-"
+
+// Cache is a directory of cache entries
+type Cache struct {
+	dir string
+}
+
+// Stats describes the contents of a cache
+type Stats struct {
+	// Entries is the number of stored entries
+	Entries int
+
+	// Size is the total size of the entries in bytes, including the
+	// temporary files left by interrupted runs
+	Size int64
+
+	// Oldest and Newest are the modification times of the least and most
+	// recently stored entries. They are zero when the cache is empty.
+	Oldest, Newest time.Time
+}
+
+// DefaultDir returns the directory of the user's goasted cache:
+// $XDG_CACHE_HOME/goasted, or the platform's user cache directory
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "goasted"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the cache directory: %w", err)
+	}
+	return filepath.Join(dir, "goasted"), nil
+}
+
+// Open opens the cache in dir, creating the directory if needed
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Dir returns the cache's directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get decodes the entry stored under key into v. It reports whether the
+// entry was found; unreadable entries count as missing.
+func (c *Cache) Get(key Key, v any) bool {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// Put stores v under key. The entry is written to a temporary file first,
+// so concurrent runs never read a partial entry.
+func (c *Cache) Put(key Key, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the number and size of the stored entries
+func (c *Cache) Stats() (Stats, error) {
+	var stats Stats
+	err := c.walk(func(path string, info fs.FileInfo, entry bool) error {
+		stats.Size += info.Size()
+		if !entry {
+			return nil
+		}
+		stats.Entries++
+		if stats.Oldest.IsZero() || info.ModTime().Before(stats.Oldest) {
+			stats.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(stats.Newest) {
+			stats.Newest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to read cache: %w", err)
+	}
+	return stats, nil
+}
+
+// Clean removes every entry and returns how many there were. Temporary
+// files left by interrupted runs are removed too.
+func (c *Cache) Clean() (int, error) {
+	removed := 0
+	err := c.walk(func(path string, info fs.FileInfo, entry bool) error {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if entry {
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to clean cache: %w", err)
+	}
+	return removed, nil
+}
+
+// walk calls fn for every entry file and temporary file, telling which it
+// is, leaving anything else in the directory alone
+func (c *Cache) walk(fn func(path string, info fs.FileInfo, entry bool) error) error {
+	shards, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() || len(shard.Name()) != 2 {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(c.dir, shard.Name()))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			isEntry := isKey(entry.Name())
+			if entry.IsDir() || !isEntry && !strings.HasPrefix(entry.Name(), tempPrefix) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err := fn(filepath.Join(c.dir, shard.Name(), entry.Name()), info, isEntry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// path returns the file of the entry stored under key. Entries are sharded
+// by the first byte of their key to keep directories small.
+func (c *Cache) path(key Key) string {
+	name := key.String()
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// Key addresses a cache entry
+type Key [sha256.Size]byte
+
+// String returns the key in hexadecimal
+func (k Key) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// isKey reports whether name is the file name of an entry
+func isKey(name string) bool {
+	_, err := hex.DecodeString(name)
+	return err == nil && len(name) == 2*sha256.Size
+}
+
+// Hasher builds a Key from everything an entry depends on
+type Hasher struct {
+	h hash.Hash
+}
+
+// NewHasher returns an empty Hasher
+func NewHasher() *Hasher {
+	return &Hasher{h: sha256.New()}
+}
+
+// Add adds strings to the key. Each is length-prefixed, so that ("ab", "c")
+// and ("a", "bc") give different keys.
+func (h *Hasher) Add(parts ...string) {
+	for _, part := range parts {
+		_, _ = fmt.Fprintf(h.h, "%d:%s", len(part), part)
+	}
+}
+
+// Sum returns the key
+func (h *Hasher) Sum() Key {
+	var key Key
+	copy(key[:], h.h.Sum(nil))
+	return key
+}
+
+// HashFile returns the hash of a file's content, in hexadecimal
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCache_PutGet(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "goasted"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	h := NewHasher()
+	h.Add("a", "b")
+	key := h.Sum()
+
+	var got []string
+	if c.Get(key, &got) {
+		t.Fatalf("Expected a miss in an empty cache, got %v", got)
+	}
+	if err := c.Put(key, []string{"x", "y"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if !c.Get(key, &got) || len(got) != 2 || got[1] != "y" {
+		t.Errorf("Expected the stored entry, got %v", got)
+	}
+
+	// A corrupt entry is a miss
+	if err := os.WriteFile(c.path(key), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if c.Get(key, &got) {
+		t.Errorf("Expected a corrupt entry to be a miss")
+	}
+}
+
+func TestHasher_LengthPrefixed(t *testing.T) {
+	a := NewHasher()
+	a.Add("ab", "c")
+	b := NewHasher()
+	b.Add("a", "bc")
+	if a.Sum() == b.Sum() {
+		t.Errorf("Expected different keys for differently split parts")
+	}
+
+	c := NewHasher()
+	c.Add("ab", "c")
+	if a.Sum() != c.Sum() {
+		t.Errorf("Expected equal keys for equal parts")
+	}
+}
+
+func TestCache_StatsAndClean(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	var key Key
+	for _, part := range []string{"a", "b", "c"} {
+		h := NewHasher()
+		h.Add(part)
+		key = h.Sum()
+		if err := c.Put(key, part); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	// A run interrupted while writing an entry leaves its temporary file
+	leftover := filepath.Join(filepath.Dir(c.path(key)), tempPrefix+"123")
+	if err := os.WriteFile(leftover, []byte("left"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Other files in the directory are left alone
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 3 || stats.Size != 13 {
+		t.Errorf("Expected 3 entries and a leftover of 13 bytes, got %+v", stats)
+	}
+	if stats.Oldest.IsZero() || stats.Newest.Before(stats.Oldest) {
+		t.Errorf("Unexpected entry times: %+v", stats)
+	}
+
+	removed, err := c.Clean()
+	if err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Expected 3 removed entries, got %d", removed)
+	}
+	if stats, _ := c.Stats(); stats.Entries != 0 || stats.Size != 0 {
+		t.Errorf("Expected an empty cache, got %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README")); err != nil {
+		t.Errorf("Expected other files to be kept: %v", err)
+	}
+}
+
+func TestDefaultDir_XDG(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg")
+	dir, err := DefaultDir()
+	if err != nil {
+		t.Fatalf("DefaultDir failed: %v", err)
+	}
+	if dir != filepath.Join("/tmp/xdg", "goasted") {
+		t.Errorf("Expected the goasted directory under XDG_CACHE_HOME, got %s", dir)
+	}
+}
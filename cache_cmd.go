@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Arneball/goasted/cache"
+)
+
+// runCache implements "goasted cache clean|stats": manage the result cache
+func runCache(args []string) int {
+	usage := func() int {
+		_, _ = fmt.Fprintln(os.Stderr, "Usage: goasted cache clean|stats [-dir <cache directory>]")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+
+	var dir string
+	fs := flag.NewFlagSet("goasted cache "+args[0], flag.ExitOnError)
+	fs.StringVar(&dir, "dir", "", "Cache directory (default: $XDG_CACHE_HOME/goasted)")
+	_ = fs.Parse(args[1:])
+
+	c, err := openCache(dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "clean":
+		removed, err := c.Clean()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Removed %d cache entries from %s\n", removed, c.Dir())
+	case "stats":
+		stats, err := c.Stats()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		printCacheStats(os.Stdout, c.Dir(), stats)
+	default:
+		return usage()
+	}
+	return 0
+}
+
+// openCache opens the cache in dir, or in the default directory
+func openCache(dir string) (*cache.Cache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = cache.DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return cache.Open(dir)
+}
+
+// printCacheStats writes the location, number and size of cache entries
+func printCacheStats(w io.Writer, dir string, stats cache.Stats) {
+	_, _ = fmt.Fprintf(w, "Directory: %s\n", dir)
+	_, _ = fmt.Fprintf(w, "Entries:   %d\n", stats.Entries)
+	_, _ = fmt.Fprintf(w, "Size:      %s\n", formatSize(stats.Size))
+	if stats.Entries > 0 {
+		_, _ = fmt.Fprintf(w, "Oldest:    %s\n", stats.Oldest.Format(time.DateTime))
+		_, _ = fmt.Fprintf(w, "Newest:    %s\n", stats.Newest.Format(time.DateTime))
+	}
+}
+
+// formatSize formats a size in bytes with a binary unit
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,227 @@
+// Package changes restricts violations to the lines touched by a change, so
+// pull requests are only roasted for code their author actually wrote.
+package changes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// fileChanges holds the added or modified lines of one file
+type fileChanges struct {
+	lines map[int]bool
+	isNew bool
+}
+
+// Set is the set of added or modified lines in a change
+type Set struct {
+	files map[string]*fileChanges
+}
+
+// FromRev diffs the working tree in dir against rev using git. Untracked
+// files are not part of the diff.
+func FromRev(dir, rev string) (*Set, error) {
+	toplevel, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+
+	// Explicit prefixes override diff.noprefix and diff.mnemonicPrefix,
+	// which would change the file names patchPath expects
+	patch, err := git(dir, "diff", "--no-color", "--no-ext-diff", "--unified=0", "--src-prefix=a/", "--dst-prefix=b/", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(strings.NewReader(patch), strings.TrimSpace(toplevel))
+}
+
+// FromPatch reads a unified diff from path. File names in the patch are
+// resolved relative to baseDir.
+func FromPatch(path, baseDir string) (*Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open patch: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return Parse(f, baseDir)
+}
+
+// git runs a git command in dir and returns its output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// Parse parses a unified diff. Only the new side of each file is recorded;
+// deleted files are ignored.
+func Parse(r io.Reader, baseDir string) (*Set, error) {
+	set := &Set{files: make(map[string]*fileChanges)}
+
+	var current *fileChanges
+	var fromDevNull bool
+	var newLine, oldLeft, newLeft int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Inside a hunk every line is content, even if it looks like a header
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				if current != nil {
+					current.lines[newLine] = true
+				}
+				newLine++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, " "), line == "":
+				newLine++
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			fromDevNull = strings.TrimSpace(line[4:]) == "/dev/null"
+			current = nil
+
+		case strings.HasPrefix(line, "+++ "):
+			name := patchPath(line[4:])
+			if name == "" {
+				current = nil
+				continue
+			}
+			key := canonical(filepath.Join(baseDir, filepath.FromSlash(name)))
+			current = set.files[key]
+			if current == nil {
+				current = &fileChanges{lines: make(map[int]bool)}
+				set.files[key] = current
+			}
+			current.isNew = current.isNew || fromDevNull
+
+		case strings.HasPrefix(line, "@@ "):
+			var err error
+			newLine, oldLeft, newLeft, err = parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	return set, nil
+}
+
+// patchPath extracts the file name from a "+++" header, stripping the b/
+// prefix. It returns "" for /dev/null.
+func patchPath(header string) string {
+	// Drop a trailing timestamp as written by diff -u
+	if i := strings.Index(header, "\t"); i >= 0 {
+		header = header[:i]
+	}
+	header = strings.TrimSpace(header)
+	if unquoted, err := strconv.Unquote(header); err == nil {
+		header = unquoted
+	}
+	if header == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(header, "b/") {
+		header = header[2:]
+	}
+	return header
+}
+
+// parseHunkHeader parses a "@@ -a,b +c,d @@" header into the new-side start
+// line and the number of old and new lines in the hunk
+func parseHunkHeader(header string) (start, oldCount, newCount int, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header: %s", header)
+	}
+
+	_, oldCount, err = parseRange(fields[1][1:])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header: %s", header)
+	}
+	start, newCount, err = parseRange(fields[2][1:])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header: %s", header)
+	}
+	return start, oldCount, newCount, nil
+}
+
+// parseRange parses "start,count" or "start", where count defaults to 1
+func parseRange(r string) (int, int, error) {
+	startText, countText, hasCount := strings.Cut(r, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !hasCount {
+		return start, 1, nil
+	}
+	count, err := strconv.Atoi(countText)
+	return start, count, err
+}
+
+// canonical returns an absolute, symlink-free path used to match files
+func canonical(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// Contains reports whether a violation lies on an added or modified line.
+// Violations without a line count only for files added by the change.
+func (s *Set) Contains(v rules.Violation) bool {
+	fc, ok := s.files[canonical(v.File)]
+	if !ok {
+		return false
+	}
+	if v.Line <= 0 {
+		return fc.isNew
+	}
+	return fc.lines[v.Line]
+}
+
+// Filter returns the violations that lie on added or modified lines
+func (s *Set) Filter(violations []rules.Violation) []rules.Violation {
+	var kept []rules.Violation
+	for _, v := range violations {
+		if s.Contains(v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,123 @@
+package changes
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+const testPatch = `diff --git a/service.go b/service.go
+index 1111111..2222222 100644
+--- a/service.go
++++ b/service.go
+@@ -3,0 +4 @@ import (
++	"github.com/go-kit/kit/endpoint"
+@@ -10,3 +11,4 @@ func (s *Service) Get() error {
+ 	ctx := context.Background()
+-	_, err := s.db.QueryContext(ctx, "SELECT 1")
++	_, err := s.db.Query("SELECT 1")
++++counter
+ 	return err
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package service
++
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package service
+`
+
+func violation(dir, file string, line int) rules.Violation {
+	return rules.Violation{File: filepath.Join(dir, file), Line: line, Rule: "test-rule"}
+}
+
+func TestParse_AddedLines(t *testing.T) {
+	dir := t.TempDir()
+	set, err := Parse(strings.NewReader(testPatch), dir)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	cases := []struct {
+		file string
+		line int
+		want bool
+	}{
+		{"service.go", 4, true},   // added import
+		{"service.go", 3, false},  // untouched import
+		{"service.go", 11, false}, // context line
+		{"service.go", 12, true},  // modified line
+		{"service.go", 13, true},  // added line that looks like a header
+		{"service.go", 14, false}, // context line
+		{"new.go", 1, true},
+		{"new.go", 0, true}, // whole-file finding in a new file
+		{"service.go", 0, false},
+		{"gone.go", 1, false},
+		{"other.go", 1, false},
+	}
+	for _, c := range cases {
+		if got := set.Contains(violation(dir, c.file, c.line)); got != c.want {
+			t.Errorf("Contains(%s:%d) = %v, want %v", c.file, c.line, got, c.want)
+		}
+	}
+}
+
+func TestParse_MalformedHunk(t *testing.T) {
+	_, err := Parse(strings.NewReader("--- a/x.go\n+++ b/x.go\n@@ nonsense @@\n"), t.TempDir())
+	if err == nil {
+		t.Errorf("Expected error for malformed hunk header")
+	}
+}
+
+func TestFromRev(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	run("init", "-q")
+	write("package main\n\nfunc a() {}\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	// File names are parsed the same whatever the user's prefix settings
+	run("config", "diff.mnemonicPrefix", "true")
+	write("package main\n\nfunc a() {}\n\nfunc b() {}\n")
+
+	set, err := FromRev(dir, "HEAD")
+	if err != nil {
+		t.Fatalf("FromRev failed: %v", err)
+	}
+
+	if set.Contains(violation(dir, "main.go", 3)) {
+		t.Errorf("Expected unchanged line 3 not to be contained")
+	}
+	if !set.Contains(violation(dir, "main.go", 5)) {
+		t.Errorf("Expected added line 5 to be contained")
+	}
+}
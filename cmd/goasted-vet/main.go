@@ -0,0 +1,15 @@
+// Command goasted-vet runs every goasted rule through the standard
+// go/analysis driver, so it supports the usual -json, -fix and -c flags and
+// can be used with go vet -vettool.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/multichecker"
+
+	"github.com/Arneball/goasted/goanalysis"
+	"github.com/Arneball/goasted/rules"
+)
+
+func main() {
+	multichecker.Main(goanalysis.Analyzers(rules.DefaultRegistry())...)
+}
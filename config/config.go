@@ -0,0 +1,528 @@
+// Package config loads goasted project configuration files.
+//
+// A configuration file is named .goasted.yaml, .goasted.yml or .goasted.json.
+// Files are discovered by walking up from the analyzed directory, and files
+// closer to the analyzed code override files further up, so a subdirectory
+// can carry its own file to override settings for that subtree.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// FileNames lists the recognized configuration file names
+var FileNames = []string{".goasted.yaml", ".goasted.yml", ".goasted.json"}
+
+// File is the on-disk representation of a configuration file
+type File struct {
+	// Rules configures individual rules by name
+	Rules map[string]RuleConfig `yaml:"rules" json:"rules"`
+
+	// Include lists path globs, relative to the configuration file, of files
+	// to analyze. When empty, all files are included.
+	Include []string `yaml:"include" json:"include"`
+
+	// Exclude lists path globs, relative to the configuration file, of files
+	// to skip
+	Exclude []string `yaml:"exclude" json:"exclude"`
+
+	// Plugins declares external rules by name. See the plugin package for
+	// the protocol.
+	Plugins map[string]PluginConfig `yaml:"plugins" json:"plugins"`
+
+	// Patterns declares rules matching code against patterns by name
+	Patterns map[string]PatternConfig `yaml:"patterns" json:"patterns"`
+}
+
+// RuleConfig configures a single rule
+type RuleConfig struct {
+	// Enabled turns the rule on or off. Unset means inherit, and rules are
+	// enabled by default.
+	Enabled *bool `yaml:"enabled" json:"enabled"`
+
+	// Severity overrides the rule's default severity (error, warning or info)
+	Severity string `yaml:"severity" json:"severity"`
+
+	// Options are passed to rules implementing rules.Configurable
+	Options map[string]any `yaml:"options" json:"options"`
+
+	// optionsDir is the directory of the nearest file that set options
+	optionsDir string
+}
+
+// Config is the merged configuration that applies to one directory
+type Config struct {
+	// Rules holds the merged per-rule settings
+	Rules map[string]RuleConfig
+
+	// Include holds absolute include globs; empty means include everything
+	Include []string
+
+	// Exclude holds absolute exclude globs
+	Exclude []string
+
+	// Sources lists the configuration files that were merged, furthest first
+	Sources []string
+
+	// Plugins holds the merged plugin declarations, with commands resolved
+	// against the directory of the declaring file
+	Plugins map[string]PluginConfig
+
+	// Patterns holds the merged pattern rule declarations
+	Patterns map[string]PatternConfig
+
+	mu    sync.Mutex
+	rules map[*rules.Registry][]rules.Checker
+}
+
+// Resolver finds and caches the configuration for each directory
+type Resolver struct {
+	registry *rules.Registry
+
+	mu    sync.Mutex
+	dirs  map[string]*Config
+	files map[string]*File
+
+	noDiscovery bool
+	override    *File
+	overrideDir string
+	overridden  map[string]*Config
+}
+
+// NewResolver creates a Resolver that validates rule names against registry
+func NewResolver(registry *rules.Registry) *Resolver {
+	return &Resolver{
+		registry: registry,
+		dirs:     make(map[string]*Config),
+		files:    make(map[string]*File),
+	}
+}
+
+// Registry returns the registry rule names are validated against
+func (r *Resolver) Registry() *rules.Registry {
+	return r.registry
+}
+
+// SetOverride layers file on top of the configuration of every directory,
+// as if it were found closer than any configuration file. Globs in file are
+// relative to dir.
+func (r *Resolver) SetOverride(dir string, file *File) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	if err := file.Validate(r.registry); err != nil {
+		return fmt.Errorf("invalid override: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.override = file
+	r.overrideDir = abs
+	r.overridden = make(map[string]*Config)
+	return nil
+}
+
+// SetDiscovery turns reading configuration files on or off. It's on by
+// default; with it off only the override applies.
+func (r *Resolver) SetDiscovery(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.noDiscovery = !enabled
+	r.dirs = make(map[string]*Config)
+	r.overridden = make(map[string]*Config)
+}
+
+// ForFile returns the configuration that applies to filename
+func (r *Resolver) ForFile(filename string) (*Config, error) {
+	return r.ForDir(filepath.Dir(filename))
+}
+
+// ForDir returns the merged configuration for dir, found by walking up
+// from dir to the filesystem root
+func (r *Resolver) ForDir(dir string) (*Config, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.forDirLocked(abs)
+	if err != nil || r.override == nil {
+		return cfg, err
+	}
+
+	overridden, ok := r.overridden[abs]
+	if !ok {
+		overridden = merge(cfg, r.overrideDir, "", r.override)
+		r.overridden[abs] = overridden
+	}
+	return overridden, nil
+}
+
+// forDirLocked resolves the configuration for an absolute directory
+func (r *Resolver) forDirLocked(dir string) (*Config, error) {
+	if cfg, ok := r.dirs[dir]; ok {
+		return cfg, nil
+	}
+
+	// Start from the parent's configuration
+	var parent *Config
+	if up := filepath.Dir(dir); up != dir {
+		var err error
+		if parent, err = r.forDirLocked(up); err != nil {
+			return nil, err
+		}
+	}
+
+	var path string
+	if !r.noDiscovery {
+		var err error
+		if path, err = findFile(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := parent
+	if path != "" {
+		file, err := r.loadFile(path, parent)
+		if err != nil {
+			return nil, err
+		}
+		cfg = merge(parent, dir, path, file)
+	} else if cfg == nil {
+		cfg = &Config{Rules: make(map[string]RuleConfig)}
+	}
+
+	r.dirs[dir] = cfg
+	return cfg, nil
+}
+
+// loadFile reads, parses and validates a configuration file, whose rule
+// settings may refer to the rules declared in parent
+func (r *Resolver) loadFile(path string, parent *Config) (*File, error) {
+	if file, ok := r.files[path]; ok {
+		return file, nil
+	}
+
+	file, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.validate(r.registry, parent); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	r.files[path] = file
+	return file, nil
+}
+
+// findFile returns the configuration file in dir, or "" if there is none
+func findFile(dir string) (string, error) {
+	var found []string
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			found = append(found, path)
+		}
+	}
+
+	if len(found) > 1 {
+		return "", fmt.Errorf("multiple configuration files in %s: %s", dir, strings.Join(found, ", "))
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	return "", nil
+}
+
+// ParseFile parses a YAML or JSON configuration file. Unknown keys are
+// reported as errors.
+func ParseFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	file := &File{}
+	if strings.HasSuffix(path, ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(file)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(file)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// Validate checks that all rule names exist in registry and that all
+// settings have valid values
+func (f *File) Validate(registry *rules.Registry) error {
+	return f.validate(registry, nil)
+}
+
+// validate checks the file like Validate, also accepting the names of the
+// rules declared in parent
+func (f *File) validate(registry *rules.Registry, parent *Config) error {
+	if err := f.validatePlugins(registry); err != nil {
+		return err
+	}
+	if err := f.validatePatterns(registry); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(f.Rules) {
+		rc := f.Rules[name]
+		if registry.GetChecker(name) == nil && !f.declares(name) && !parent.Declares(name) {
+			return fmt.Errorf("unknown rule %q (known rules: %s)", name, strings.Join(ruleNames(registry), ", "))
+		}
+		// Declared plugin and pattern rules never accept options
+		if len(rc.Options) > 0 {
+			if _, ok := registry.GetChecker(name).(rules.Configurable); !ok {
+				return fmt.Errorf("rule %q does not accept options", name)
+			}
+		}
+		if rc.Severity != "" {
+			if _, err := rules.ParseSeverity(rc.Severity); err != nil {
+				return fmt.Errorf("rule %q: %w", name, err)
+			}
+		}
+	}
+
+	for _, glob := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if err := rules.ValidateGlob(glob); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// declares reports whether the file declares a plugin or pattern rule name
+func (f *File) declares(name string) bool {
+	_, isPlugin := f.Plugins[name]
+	_, isPattern := f.Patterns[name]
+	return isPlugin || isPattern
+}
+
+// Declares reports whether the configuration declares a plugin or pattern
+// rule name. A nil configuration declares nothing.
+func (c *Config) Declares(name string) bool {
+	if c == nil {
+		return false
+	}
+	_, isPlugin := c.Plugins[name]
+	_, isPattern := c.Patterns[name]
+	return isPlugin || isPattern
+}
+
+// merge layers file, found in dir, on top of parent. The path of a file
+// that wasn't read from disk is empty.
+func merge(parent *Config, dir, path string, file *File) *Config {
+	cfg := &Config{
+		Rules:    make(map[string]RuleConfig),
+		Plugins:  make(map[string]PluginConfig),
+		Patterns: make(map[string]PatternConfig),
+	}
+
+	if parent != nil {
+		for name, rc := range parent.Rules {
+			cfg.Rules[name] = rc
+		}
+		for name, pc := range parent.Plugins {
+			cfg.Plugins[name] = pc
+		}
+		for name, pc := range parent.Patterns {
+			cfg.Patterns[name] = pc
+		}
+		cfg.Include = parent.Include
+		cfg.Exclude = append(cfg.Exclude, parent.Exclude...)
+		cfg.Sources = append(cfg.Sources, parent.Sources...)
+	}
+	if path != "" {
+		cfg.Sources = append(cfg.Sources, path)
+	}
+
+	for name, rc := range file.Rules {
+		merged := cfg.Rules[name]
+		if rc.Enabled != nil {
+			merged.Enabled = rc.Enabled
+		}
+		if rc.Severity != "" {
+			merged.Severity = rc.Severity
+		}
+		if len(rc.Options) > 0 {
+			options := make(map[string]any, len(merged.Options)+len(rc.Options))
+			for k, v := range merged.Options {
+				options[k] = v
+			}
+			for k, v := range rc.Options {
+				options[k] = v
+			}
+			merged.Options = options
+			merged.optionsDir = dir
+		}
+		cfg.Rules[name] = merged
+	}
+
+	// Declarations closer to the code override those further up, even of
+	// the other kind
+	for name, pc := range file.Plugins {
+		delete(cfg.Patterns, name)
+		cfg.Plugins[name] = resolveCommand(dir, pc)
+	}
+	for name, pc := range file.Patterns {
+		delete(cfg.Plugins, name)
+		cfg.Patterns[name] = pc
+	}
+
+	// The nearest file that sets include globs wins; excludes accumulate
+	if len(file.Include) > 0 {
+		cfg.Include = nil
+		for _, glob := range file.Include {
+			cfg.Include = append(cfg.Include, rules.AnchorGlob(dir, glob))
+		}
+	}
+	for _, glob := range file.Exclude {
+		cfg.Exclude = append(cfg.Exclude, rules.AnchorGlob(dir, glob))
+	}
+
+	return cfg
+}
+
+// Included reports whether filename passes the include and exclude globs
+func (c *Config) Included(filename string) bool {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return true
+	}
+	abs = filepath.ToSlash(abs)
+
+	for _, glob := range c.Exclude {
+		if rules.MatchGlob(glob, abs) {
+			return false
+		}
+	}
+
+	if len(c.Include) == 0 {
+		return true
+	}
+	for _, glob := range c.Include {
+		if rules.MatchGlob(glob, abs) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether the named rule is enabled
+func (c *Config) Enabled(name string) bool {
+	rc, ok := c.Rules[name]
+	return !ok || rc.Enabled == nil || *rc.Enabled
+}
+
+// Severity returns the configured severity of the named rule, or
+// rules.SeverityUnset if the configuration doesn't override it
+func (c *Config) Severity(name string) rules.Severity {
+	severity, err := rules.ParseSeverity(c.Rules[name].Severity)
+	if err != nil {
+		return rules.SeverityUnset
+	}
+	return severity
+}
+
+// EnabledRules returns the enabled rules from registry, configured with
+// their options. The result is cached per registry.
+func (c *Config) EnabledRules(registry *rules.Registry) ([]rules.Checker, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if enabled, ok := c.rules[registry]; ok {
+		return enabled, nil
+	}
+
+	enabled, err := c.enabledRules(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.rules == nil {
+		c.rules = make(map[*rules.Registry][]rules.Checker)
+	}
+	c.rules[registry] = enabled
+	return enabled, nil
+}
+
+// enabledRules filters and configures the rules of registry
+func (c *Config) enabledRules(registry *rules.Registry) ([]rules.Checker, error) {
+	var enabled []rules.Checker
+	for _, rule := range registry.GetCheckers() {
+		if !c.Enabled(rule.Name()) {
+			continue
+		}
+
+		rc := c.Rules[rule.Name()]
+		if len(rc.Options) > 0 {
+			configurable, ok := rule.(rules.Configurable)
+			if !ok {
+				return nil, fmt.Errorf("rule %q does not accept options", rule.Name())
+			}
+			configure := configurable.Configure
+			if inDir, ok := rule.(rules.ConfigurableInDir); ok && rc.optionsDir != "" {
+				configure = func(options map[string]any) (rules.Checker, error) {
+					return inDir.ConfigureInDir(rc.optionsDir, options)
+				}
+			}
+			configured, err := configure(rc.Options)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", rule.Name(), err)
+			}
+			rule = configured
+		}
+
+		enabled = append(enabled, rule)
+	}
+	return enabled, nil
+}
+
+// ruleNames returns the names of all rules in registry
+func ruleNames(registry *rules.Registry) []string {
+	var names []string
+	for _, rule := range registry.GetCheckers() {
+		names = append(names, rule.Name())
+	}
+	return names
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+func enabledNames(t *testing.T, cfg *Config) []string {
+	t.Helper()
+
+	enabled, err := cfg.EnabledRules(rules.DefaultRegistry())
+	if err != nil {
+		t.Fatalf("EnabledRules failed: %v", err)
+	}
+	var names []string
+	for _, rule := range enabled {
+		names = append(names, rule.Name())
+	}
+	return names
+}
+
+func TestResolver_NoConfigEnablesAllRules(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg, err := NewResolver(rules.DefaultRegistry()).ForDir(dir)
+	if err != nil {
+		t.Fatalf("ForDir failed: %v", err)
+	}
+
+	if got := len(enabledNames(t, cfg)); got != 4 {
+		t.Errorf("Expected 4 enabled rules, got %d", got)
+	}
+}
+
+func TestResolver_MergesParentAndChild(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+rules:
+  testify-usage:
+    enabled: false
+  gokit-usage:
+    severity: warning
+exclude:
+  - vendor/**
+`)
+	child := filepath.Join(root, "legacy")
+	writeConfig(t, child, ".goasted.json", `{
+  "rules": {
+    "sql-context-required": {"enabled": false},
+    "testify-usage": {"enabled": true}
+  }
+}`)
+
+	resolver := NewResolver(rules.DefaultRegistry())
+
+	rootCfg, err := resolver.ForDir(root)
+	if err != nil {
+		t.Fatalf("ForDir failed: %v", err)
+	}
+	if got := strings.Join(enabledNames(t, rootCfg), ","); got != "sql-context-required,gokit-usage,banned-imports" {
+		t.Errorf("Unexpected root rules: %s", got)
+	}
+
+	childCfg, err := resolver.ForFile(filepath.Join(child, "adapter.go"))
+	if err != nil {
+		t.Fatalf("ForFile failed: %v", err)
+	}
+	if got := strings.Join(enabledNames(t, childCfg), ","); got != "testify-usage,gokit-usage,banned-imports" {
+		t.Errorf("Unexpected child rules: %s", got)
+	}
+	if got := childCfg.Rules["gokit-usage"].Severity; got != "warning" {
+		t.Errorf("Expected inherited severity warning, got %q", got)
+	}
+	if len(childCfg.Sources) != 2 {
+		t.Errorf("Expected 2 config sources, got %d", len(childCfg.Sources))
+	}
+}
+
+func TestResolver_IncludeExclude(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yml", `
+include:
+  - internal/**
+exclude:
+  - "**/*_gen.go"
+  - internal/legacy
+`)
+
+	cfg, err := NewResolver(rules.DefaultRegistry()).ForDir(root)
+	if err != nil {
+		t.Fatalf("ForDir failed: %v", err)
+	}
+
+	cases := map[string]bool{
+		"internal/service.go":        true,
+		"internal/deep/pkg/x.go":     true,
+		"internal/models_gen.go":     false,
+		"internal/legacy/adapter.go": false,
+		"cmd/main.go":                false,
+	}
+	for file, want := range cases {
+		if got := cfg.Included(filepath.Join(root, file)); got != want {
+			t.Errorf("Included(%s) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestResolver_UnknownRule(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+rules:
+  no-such-rule:
+    enabled: false
+`)
+
+	_, err := NewResolver(rules.DefaultRegistry()).ForDir(root)
+	if err == nil || !strings.Contains(err.Error(), `unknown rule "no-such-rule"`) {
+		t.Errorf("Expected unknown rule error, got %v", err)
+	}
+}
+
+func TestResolver_UnknownKey(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+rules:
+  gokit-usage:
+    enabeld: false
+`)
+
+	_, err := NewResolver(rules.DefaultRegistry()).ForDir(root)
+	if err == nil || !strings.Contains(err.Error(), "enabeld") {
+		t.Errorf("Expected unknown key error, got %v", err)
+	}
+}
+
+func TestResolver_UnknownJSONKey(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.json", `{"rulez": {}}`)
+
+	_, err := NewResolver(rules.DefaultRegistry()).ForDir(root)
+	if err == nil || !strings.Contains(err.Error(), "rulez") {
+		t.Errorf("Expected unknown key error, got %v", err)
+	}
+}
+
+func TestResolver_InvalidSeverity(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+rules:
+  gokit-usage:
+    severity: fatal
+`)
+
+	_, err := NewResolver(rules.DefaultRegistry()).ForDir(root)
+	if err == nil || !strings.Contains(err.Error(), "invalid severity") {
+		t.Errorf("Expected invalid severity error, got %v", err)
+	}
+}
+
+func TestResolver_OptionsRequireConfigurableRule(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+rules:
+  gokit-usage:
+    options:
+      prefix: foo
+`)
+
+	if _, err := NewResolver(rules.DefaultRegistry()).ForDir(root); err == nil {
+		t.Errorf("Expected error for options on a rule that does not accept them")
+	}
+}
+
+func TestResolver_MultipleFilesInOneDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", "")
+	writeConfig(t, root, ".goasted.json", "{}")
+
+	if _, err := NewResolver(rules.DefaultRegistry()).ForDir(root); err == nil {
+		t.Errorf("Expected error for multiple config files")
+	}
+}
+
+func TestResolver_PluginRuleSettings(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+plugins:
+  no-println:
+    command: [./bin/no-println]
+rules:
+  no-println:
+    severity: warning
+`)
+
+	if _, err := NewResolver(rules.DefaultRegistry()).ForDir(root); err != nil {
+		t.Errorf("Expected settings for a declared plugin to be valid, got %v", err)
+	}
+}
+
+func TestResolver_InvalidPlugins(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"missing command", "plugins:\n  x:\n    description: d\n", "command is required"},
+		{"unknown send", "plugins:\n  x:\n    command: [x]\n    send: [ast]\n", `unknown send value "ast"`},
+		{"built-in name", "plugins:\n  gokit-usage:\n    command: [x]\n", "name of a built-in rule"},
+		{"bad severity", "plugins:\n  x:\n    command: [x]\n    severity: fatal\n", "invalid severity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeConfig(t, root, ".goasted.yaml", tt.config)
+
+			_, err := NewResolver(rules.DefaultRegistry()).ForDir(root)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestResolver_NearestPluginDeclarationWins(t *testing.T) {
+	root := t.TempDir()
+	child := filepath.Join(root, "child")
+	writeConfig(t, root, ".goasted.yaml", `
+plugins:
+  a:
+    command: [./tools/a]
+  b:
+    command: [lint-b, --strict]
+`)
+	writeConfig(t, child, ".goasted.yaml", `
+plugins:
+  a:
+    command: [./other/a]
+`)
+
+	cfg, err := NewResolver(rules.DefaultRegistry()).ForDir(child)
+	if err != nil {
+		t.Fatalf("ForDir failed: %v", err)
+	}
+	if got := cfg.Plugins["a"].Command[0]; got != filepath.Join(child, "other", "a") {
+		t.Errorf("Expected the child's command resolved against its directory, got %s", got)
+	}
+	if got := strings.Join(cfg.Plugins["b"].Command, " "); got != "lint-b --strict" {
+		t.Errorf("Expected bare command names left for PATH lookup, got %s", got)
+	}
+}
+
+func TestCheckTypeString(t *testing.T) {
+	for _, typ := range []string{
+		"*database/sql.DB",
+		"[]gopkg.in/yaml.v3.Node",
+		"map[string]example.com/store.Item",
+		"func(ctx context.Context, args ...any) error",
+		"<-chan struct{}",
+		"example.com/list.List[int]",
+		"interface{Close() error}",
+	} {
+		if err := checkTypeString(typ); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", typ, err)
+		}
+	}
+}
+
+func TestResolver_ConfiguresBannedImports(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+rules:
+  banned-imports:
+    options:
+      imports:
+        - prefix: io/ioutil
+          files: non-test
+          allowed-in: [legacy/**]
+          replacement: os
+`)
+
+	// Allowed-in globs are relative to the config file, also for the
+	// configuration of a subdirectory
+	child := filepath.Join(root, "child")
+	cfg, err := NewResolver(rules.DefaultRegistry()).ForDir(child)
+	if err != nil {
+		t.Fatalf("ForDir failed: %v", err)
+	}
+	enabled, err := cfg.EnabledRules(rules.DefaultRegistry().Filter([]string{"banned-imports"}))
+	if err != nil {
+		t.Fatalf("Expected YAML options to configure banned-imports, got %v", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", "package legacy\n\nimport \"io/ioutil\"\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := enabled[0].(rules.Rule)
+	for filename, want := range map[string]int{
+		filepath.Join(root, "legacy", "x.go"):           0,
+		filepath.Join(child, "legacy", "x.go"):          1,
+		filepath.Join(root, "legacy", "deeper", "x.go"): 0,
+	} {
+		violations := rule.Check(&rules.Context{FileSet: fset, File: file, Filename: filename})
+		if len(violations) != want {
+			t.Errorf("%s: expected %d violations, got %d", filename, want, len(violations))
+		}
+	}
+}
+
+func TestResolver_InvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"missing pattern", "patterns:\n  x:\n    message: m\n", "pattern is required"},
+		{"missing message", "patterns:\n  x:\n    pattern: $x.Close()\n", "message is required"},
+		{"built-in name", "patterns:\n  gokit-usage:\n    pattern: x\n    message: m\n", "name of another rule"},
+		{"plugin name", "plugins:\n  x:\n    command: [x]\npatterns:\n  x:\n    pattern: x\n    message: m\n", "also declared as a plugin"},
+		{"short package", "patterns:\n  x:\n    pattern: $db.Query()\n    where: {$db: \"*sql.DB\"}\n    message: m\n", "unknown package sql"},
+		{"unqualified type", "patterns:\n  x:\n    pattern: $db.Query()\n    where: {$db: \"*DB\"}\n    message: m\n", "DB is not a predeclared type"},
+		{"not a type", "patterns:\n  x:\n    pattern: $db.Query()\n    where: {$db: \"*database/sql.DB(\"}\n    message: m\n", "invalid type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeConfig(t, root, ".goasted.yaml", tt.config)
+
+			_, err := NewResolver(rules.DefaultRegistry()).ForDir(root)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestResolver_Override(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, ".goasted.yaml", `
+rules:
+  gokit-usage:
+    severity: warning
+  testify-usage:
+    enabled: false
+`)
+	enabled := true
+	override := &File{
+		Rules:   map[string]RuleConfig{"testify-usage": {Enabled: &enabled}},
+		Exclude: []string{"gen/**"},
+	}
+
+	resolver := NewResolver(rules.DefaultRegistry())
+	if err := resolver.SetOverride(root, override); err != nil {
+		t.Fatalf("SetOverride failed: %v", err)
+	}
+	cfg, err := resolver.ForDir(filepath.Join(root, "pkg"))
+	if err != nil {
+		t.Fatalf("ForDir failed: %v", err)
+	}
+	if !cfg.Enabled("testify-usage") || cfg.Rules["gokit-usage"].Severity != "warning" {
+		t.Errorf("Expected the override on top of the file, got %+v", cfg.Rules)
+	}
+	if cfg.Included(filepath.Join(root, "gen", "x.go")) {
+		t.Errorf("Expected override globs relative to its directory")
+	}
+	if len(cfg.Sources) != 1 {
+		t.Errorf("Expected only the file as a source, got %v", cfg.Sources)
+	}
+
+	resolver.SetDiscovery(false)
+	cfg, err = resolver.ForDir(root)
+	if err != nil {
+		t.Fatalf("ForDir failed: %v", err)
+	}
+	if cfg.Rules["gokit-usage"].Severity != "" || len(cfg.Sources) != 0 {
+		t.Errorf("Expected configuration files to be ignored, got %+v", cfg)
+	}
+}
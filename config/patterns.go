@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// PatternConfig declares a rule matching code shaped like a pattern. See the
+// pattern package for the syntax.
+type PatternConfig struct {
+	// Pattern is a Go expression with metavariables, e.g. "$db.Query($*args)"
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// Where constrains metavariables to types, e.g. {"$db": "*database/sql.DB"}
+	Where map[string]string `yaml:"where" json:"where"`
+
+	// Message is reported for each match. Metavariables are replaced by the
+	// code they matched.
+	Message string `yaml:"message" json:"message"`
+
+	// Description is shown in rule listings
+	Description string `yaml:"description" json:"description"`
+
+	// Severity is the rule's default severity. It defaults to error.
+	Severity string `yaml:"severity" json:"severity"`
+
+	// Rewrite is an optional expression template offered as a suggested fix,
+	// e.g. "$db.QueryContext(ctx, $*args)"
+	Rewrite string `yaml:"rewrite" json:"rewrite"`
+}
+
+// validatePatterns checks the pattern rule declarations of a file. The
+// patterns themselves are compiled when the rules are registered.
+func (f *File) validatePatterns(registry *rules.Registry) error {
+	for _, name := range sortedKeys(f.Patterns) {
+		pc := f.Patterns[name]
+		if name == "" || strings.ContainsAny(name, " \t,") {
+			return fmt.Errorf("invalid pattern rule name %q", name)
+		}
+		if _, ok := f.Plugins[name]; ok {
+			return fmt.Errorf("pattern rule %q is also declared as a plugin", name)
+		}
+		if rule := registry.GetChecker(name); rule != nil {
+			if _, isPattern := rule.(patternRule); !isPattern {
+				return fmt.Errorf("pattern rule %q has the name of another rule", name)
+			}
+		}
+		if strings.TrimSpace(pc.Pattern) == "" {
+			return fmt.Errorf("pattern rule %q: pattern is required", name)
+		}
+		for _, metavar := range sortedKeys(pc.Where) {
+			if err := checkTypeString(pc.Where[metavar]); err != nil {
+				return fmt.Errorf("pattern rule %q: where %s: %w", name, metavar, err)
+			}
+		}
+		if pc.Message == "" {
+			return fmt.Errorf("pattern rule %q: message is required", name)
+		}
+		if pc.Severity != "" {
+			if _, err := rules.ParseSeverity(pc.Severity); err != nil {
+				return fmt.Errorf("pattern rule %q: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// patternRule is implemented by rules compiled from patterns, so that
+// registering declared patterns doesn't make their own declarations invalid
+type patternRule interface {
+	Pattern() PatternConfig
+}
+
+// qualifiedName matches a type name qualified by its package path, as
+// go/types prints it, e.g. "database/sql.DB"
+var qualifiedName = regexp.MustCompile(`\w[\w.~-]*(/[\w.~-]+)*\.([A-Za-z_]\w*)`)
+
+// packagePlaceholder stands for package paths, which aren't Go syntax, when
+// parsing a type string
+const packagePlaceholder = "goasted_pkg"
+
+// checkTypeString checks that typ is a type written as go/types prints it:
+// Go syntax, with named types qualified by their package path
+func checkTypeString(typ string) error {
+	for _, m := range qualifiedName.FindAllStringSubmatch(typ, -1) {
+		path := strings.TrimSuffix(m[0], "."+m[2])
+		if !isPackagePath(path) {
+			return fmt.Errorf("invalid type %q: unknown package %s; qualify named types with their full package path, e.g. *database/sql.DB", typ, path)
+		}
+	}
+	src := qualifiedName.ReplaceAllString(typ, packagePlaceholder+".$2")
+	expr, err := parser.ParseExpr(src)
+	if err != nil {
+		return fmt.Errorf("invalid type %q", typ)
+	}
+	if err := checkTypeExpr(expr); err != nil {
+		return fmt.Errorf("invalid type %q: %w", typ, err)
+	}
+	return nil
+}
+
+// isPackagePath reports whether path may be the path of a package. Paths
+// without a dot, slash or dash are reserved for the standard library, such as
+// "time", so a short name such as "sql" is a mistake.
+func isPackagePath(path string) bool {
+	if strings.ContainsAny(path, "./-") || build.Default.GOROOT == "" {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", path))
+	return err == nil && info.IsDir()
+}
+
+// checkTypeExpr checks a parsed type string. Unqualified names must be
+// predeclared types, as go/types qualifies all others.
+func checkTypeExpr(expr ast.Expr) error {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if _, ok := types.Universe.Lookup(e.Name).(*types.TypeName); !ok {
+			return fmt.Errorf("%s is not a predeclared type; qualify named types with their package path, e.g. *database/sql.DB", e.Name)
+		}
+		return nil
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && x.Name == packagePlaceholder {
+			return nil
+		}
+	case *ast.StarExpr:
+		return checkTypeExpr(e.X)
+	case *ast.ParenExpr:
+		return checkTypeExpr(e.X)
+	case *ast.Ellipsis:
+		return checkTypeExpr(e.Elt)
+	case *ast.ArrayType:
+		return checkTypeExpr(e.Elt)
+	case *ast.ChanType:
+		return checkTypeExpr(e.Value)
+	case *ast.MapType:
+		if err := checkTypeExpr(e.Key); err != nil {
+			return err
+		}
+		return checkTypeExpr(e.Value)
+	case *ast.IndexExpr:
+		if err := checkTypeExpr(e.X); err != nil {
+			return err
+		}
+		return checkTypeExpr(e.Index)
+	case *ast.IndexListExpr:
+		if err := checkTypeExpr(e.X); err != nil {
+			return err
+		}
+		for _, index := range e.Indices {
+			if err := checkTypeExpr(index); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ast.FuncType:
+		if err := checkFieldTypes(e.Params); err != nil {
+			return err
+		}
+		return checkFieldTypes(e.Results)
+	case *ast.StructType:
+		return checkFieldTypes(e.Fields)
+	case *ast.InterfaceType:
+		return checkFieldTypes(e.Methods)
+	}
+	return fmt.Errorf("%s is not a type", types.ExprString(expr))
+}
+
+// checkFieldTypes checks the types of the fields, parameters or methods of
+// a parsed type string
+func checkFieldTypes(fields *ast.FieldList) error {
+	if fields == nil {
+		return nil
+	}
+	for _, field := range fields.List {
+		if err := checkTypeExpr(field.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// Plugin data that can be requested in PluginConfig.Send
+const (
+	// SendSource sends the file's source text
+	SendSource = "source"
+
+	// SendUses sends every identifier use with its position and the object
+	// it resolves to
+	SendUses = "uses"
+)
+
+// PluginConfig declares an external rule executable
+type PluginConfig struct {
+	// Command is the executable followed by its arguments. A relative path
+	// containing a slash is resolved against the configuration file's
+	// directory; a bare name is looked up in PATH.
+	Command []string `yaml:"command" json:"command"`
+
+	// Description is shown in rule listings
+	Description string `yaml:"description" json:"description"`
+
+	// Severity is the plugin's default severity. It defaults to error.
+	Severity string `yaml:"severity" json:"severity"`
+
+	// Send lists the optional data to send with each file: "source" and
+	// "uses"
+	Send []string `yaml:"send" json:"send"`
+}
+
+// validatePlugins checks the plugin declarations of a file
+func (f *File) validatePlugins(registry *rules.Registry) error {
+	for _, name := range sortedKeys(f.Plugins) {
+		pc := f.Plugins[name]
+		if name == "" || strings.ContainsAny(name, " \t,") {
+			return fmt.Errorf("invalid plugin name %q", name)
+		}
+		if rule := registry.GetChecker(name); rule != nil {
+			if _, isPlugin := rule.(pluginRule); !isPlugin {
+				return fmt.Errorf("plugin %q has the name of a built-in rule", name)
+			}
+		}
+		if len(pc.Command) == 0 || pc.Command[0] == "" {
+			return fmt.Errorf("plugin %q: command is required", name)
+		}
+		if pc.Severity != "" {
+			if _, err := rules.ParseSeverity(pc.Severity); err != nil {
+				return fmt.Errorf("plugin %q: %w", name, err)
+			}
+		}
+		for _, send := range pc.Send {
+			if send != SendSource && send != SendUses {
+				return fmt.Errorf("plugin %q: unknown send value %q (valid options: %s, %s)", name, send, SendSource, SendUses)
+			}
+		}
+	}
+	return nil
+}
+
+// pluginRule is implemented by rules wrapping plugins, so that registering
+// declared plugins doesn't make their own declarations invalid
+type pluginRule interface {
+	Plugin() PluginConfig
+}
+
+// resolveCommand makes a relative command path absolute against dir
+func resolveCommand(dir string, pc PluginConfig) PluginConfig {
+	if len(pc.Command) == 0 {
+		return pc
+	}
+	exe := filepath.FromSlash(pc.Command[0])
+	if !filepath.IsAbs(exe) && strings.ContainsRune(exe, os.PathSeparator) {
+		command := append([]string{filepath.Join(dir, exe)}, pc.Command[1:]...)
+		pc.Command = command
+	}
+	return pc
+}
@@ -0,0 +1,218 @@
+package fixer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each hunk
+const contextLines = 3
+
+// opKind is the kind of a single line-level diff operation
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a single line-level diff operation
+type op struct {
+	kind opKind
+	line string
+}
+
+// UnifiedDiff returns a unified diff between two versions of a file, or ""
+// if they are identical
+func UnifiedDiff(name string, original, fixed []byte) string {
+	a := splitLines(string(original))
+	b := splitLines(string(fixed))
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+	for _, h := range hunks(ops) {
+		if sb.Len() == 0 {
+			_, _ = fmt.Fprintf(&sb, "--- %s\n+++ %s\n", name, name)
+		}
+		sb.WriteString(h)
+	}
+	return sb.String()
+}
+
+// splitLines splits text into lines, keeping the line terminators
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script between a and b using Myers'
+// O(ND) algorithm
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+
+	// trace[d] holds v for the diagonals -d-1..d+1 before step d, which is
+	// all that backtracking reads, keeping the trace at O(D²) rather than
+	// O((N+M)·D)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the Myers trace backwards to recover the edit script
+func backtrack(trace [][]int, a, b []string) []op {
+	var ops []op
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d][d+1+k] is v[k]
+		v, offset := trace[d], d+1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: opEqual, line: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, op{kind: opInsert, line: b[y]})
+			} else {
+				x--
+				ops = append(ops, op{kind: opDelete, line: a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// hunks groups an edit script into unified diff hunks
+func hunks(ops []op) []string {
+	var result []string
+
+	i := 0
+	for i < len(ops) {
+		// Find the next change
+		for i < len(ops) && ops[i].kind == opEqual {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while changes are within 2*contextLines of each other
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end += min(contextLines, run-end)
+				break
+			}
+			end = run
+		}
+
+		result = append(result, formatHunk(ops, start, end))
+		i = end
+	}
+
+	return result
+}
+
+// formatHunk renders ops[start:end] as a single hunk
+func formatHunk(ops []op, start, end int) string {
+	// Compute the starting line numbers in both files
+	oldLine, newLine := 1, 1
+	for _, o := range ops[:start] {
+		if o.kind != opInsert {
+			oldLine++
+		}
+		if o.kind != opDelete {
+			newLine++
+		}
+	}
+
+	var oldCount, newCount int
+	var body strings.Builder
+	for _, o := range ops[start:end] {
+		prefix := " "
+		switch o.kind {
+		case opEqual:
+			oldCount++
+			newCount++
+		case opDelete:
+			prefix = "-"
+			oldCount++
+		case opInsert:
+			prefix = "+"
+			newCount++
+		}
+		body.WriteString(prefix + o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", oldLine, oldCount, newLine, newCount, body.String())
+}
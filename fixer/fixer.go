@@ -0,0 +1,192 @@
+// Package fixer applies the suggested fixes attached to rule violations.
+package fixer
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// Result describes the outcome of applying fixes to a set of files
+type Result struct {
+	// Files maps each changed file to its original and fixed contents
+	Files map[string]FileChange
+
+	// Fixed contains the violations whose first suggested fix was applied
+	Fixed []rules.Violation
+
+	// Skipped contains violations whose fix conflicted with an earlier fix
+	// or could not be applied
+	Skipped []rules.Violation
+}
+
+// FileChange holds the contents of a file before and after fixing
+type FileChange struct {
+	Original []byte
+	Fixed    []byte
+}
+
+// Apply computes the result of applying the first suggested fix of every
+// violation. Files are read from disk but never written; use Write for that.
+//
+// Fixes are applied in position order. A fix whose edits overlap an edit of a
+// previously accepted fix is skipped, and identical edits (for example from
+// the same violation being reported twice) are applied only once. Each fixed
+// file is run through gofmt, and a file whose result does not parse is left
+// unchanged.
+func Apply(violations []rules.Violation) (*Result, error) {
+	result := &Result{Files: make(map[string]FileChange)}
+
+	// Group fixable violations by file
+	byFile := make(map[string][]rules.Violation)
+	var files []string
+	for _, v := range violations {
+		if len(v.SuggestedFixes) == 0 {
+			continue
+		}
+		file := v.File
+		if edits := v.SuggestedFixes[0].Edits; len(edits) > 0 && edits[0].File != "" {
+			file = edits[0].File
+		}
+		if _, ok := byFile[file]; !ok {
+			files = append(files, file)
+		}
+		byFile[file] = append(byFile[file], v)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		original, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		fixed, applied, skipped := applyToFile(file, original, byFile[file])
+		result.Skipped = append(result.Skipped, skipped...)
+		if len(applied) == 0 {
+			continue
+		}
+
+		formatted, err := format.Source(fixed)
+		if err != nil {
+			result.Skipped = append(result.Skipped, applied...)
+			continue
+		}
+
+		result.Fixed = append(result.Fixed, applied...)
+		result.Files[file] = FileChange{Original: original, Fixed: formatted}
+	}
+
+	return result, nil
+}
+
+// Write writes all fixed files back to disk
+func (r *Result) Write() error {
+	for file, change := range r.Files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+		if err := os.WriteFile(file, change.Fixed, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// applyToFile applies the fixes for the violations of a single file
+func applyToFile(file string, src []byte, violations []rules.Violation) ([]byte, []rules.Violation, []rules.Violation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		return firstEditStart(violations[i]) < firstEditStart(violations[j])
+	})
+
+	var accepted []rules.TextEdit
+	var applied, skipped []rules.Violation
+
+	for _, v := range violations {
+		edits, ok := acceptEdits(file, len(src), accepted, v.SuggestedFixes[0].Edits)
+		if !ok {
+			skipped = append(skipped, v)
+			continue
+		}
+		accepted = append(accepted, edits...)
+		applied = append(applied, v)
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].Start < accepted[j].Start
+	})
+
+	var out []byte
+	last := 0
+	for _, e := range accepted {
+		out = append(out, src[last:e.Start]...)
+		out = append(out, e.NewText...)
+		last = e.End
+	}
+	out = append(out, src[last:]...)
+
+	return out, applied, skipped
+}
+
+// acceptEdits checks the edits of one fix against the already accepted
+// edits. It returns the edits that still need applying, or false if any edit
+// is out of range or overlaps a different accepted edit.
+func acceptEdits(file string, size int, accepted []rules.TextEdit, edits []rules.TextEdit) ([]rules.TextEdit, bool) {
+	var pending []rules.TextEdit
+	for _, e := range edits {
+		if e.File != "" && e.File != file {
+			return nil, false
+		}
+		if e.Start < 0 || e.End < e.Start || e.End > size {
+			return nil, false
+		}
+
+		duplicate := false
+		for _, a := range accepted {
+			if a.Start == e.Start && a.End == e.End && a.NewText == e.NewText {
+				duplicate = true
+				break
+			}
+			if overlaps(a, e) {
+				return nil, false
+			}
+		}
+		if !duplicate {
+			pending = append(pending, e)
+		}
+	}
+
+	for i := range pending {
+		for j := i + 1; j < len(pending); j++ {
+			if overlaps(pending[i], pending[j]) {
+				return nil, false
+			}
+		}
+	}
+
+	return pending, true
+}
+
+// overlaps reports whether two edits touch the same bytes. Two insertions at
+// the same offset also conflict, since their order would be ambiguous.
+func overlaps(a, b rules.TextEdit) bool {
+	if a.Start == b.Start {
+		return true
+	}
+	return a.Start < b.End && b.Start < a.End
+}
+
+// firstEditStart returns the smallest start offset of a violation's first fix
+func firstEditStart(v rules.Violation) int {
+	start := -1
+	for _, e := range v.SuggestedFixes[0].Edits {
+		if start == -1 || e.Start < start {
+			start = e.Start
+		}
+	}
+	return start
+}
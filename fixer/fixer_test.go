@@ -0,0 +1,178 @@
+package fixer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func writeTestFile(t *testing.T, src string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return file
+}
+
+func replace(file string, start, end int, text string) rules.Violation {
+	return rules.Violation{
+		File: file,
+		Rule: "test-rule",
+		SuggestedFixes: []rules.SuggestedFix{{
+			Message: "replace",
+			Edits:   []rules.TextEdit{{File: file, Start: start, End: end, NewText: text}},
+		}},
+	}
+}
+
+func TestApply_AppliesAndFormats(t *testing.T) {
+	src := "package main\n\nfunc main() { foo() }\n"
+	file := writeTestFile(t, src)
+	start := strings.Index(src, "foo")
+
+	result, err := Apply([]rules.Violation{replace(file, start, start+3, "bar")})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	want := "package main\n\nfunc main() { bar() }\n"
+	if got := string(result.Files[file].Fixed); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if len(result.Fixed) != 1 {
+		t.Errorf("Expected 1 fixed violation, got %d", len(result.Fixed))
+	}
+
+	// Apply must not touch the file on disk
+	if data, _ := os.ReadFile(file); string(data) != src {
+		t.Errorf("Apply modified the file on disk")
+	}
+}
+
+func TestApply_SkipsConflictingFixes(t *testing.T) {
+	src := "package main\n\nfunc main() { foo() }\n"
+	file := writeTestFile(t, src)
+	start := strings.Index(src, "foo")
+
+	result, err := Apply([]rules.Violation{
+		replace(file, start, start+3, "bar"),
+		replace(file, start+1, start+3, "xx"),
+	})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if len(result.Fixed) != 1 || len(result.Skipped) != 1 {
+		t.Errorf("Expected 1 fixed and 1 skipped, got %d and %d", len(result.Fixed), len(result.Skipped))
+	}
+}
+
+func TestApply_DeduplicatesIdenticalFixes(t *testing.T) {
+	src := "package main\n\nfunc main() { foo() }\n"
+	file := writeTestFile(t, src)
+	start := strings.Index(src, "foo")
+
+	result, err := Apply([]rules.Violation{
+		replace(file, start, start+3, "bar"),
+		replace(file, start, start+3, "bar"),
+	})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if got := string(result.Files[file].Fixed); !strings.Contains(got, "bar()") || strings.Contains(got, "barbar") {
+		t.Errorf("Expected identical edits to be applied once, got %q", got)
+	}
+}
+
+func TestApply_RejectsInvalidGo(t *testing.T) {
+	src := "package main\n\nfunc main() { foo() }\n"
+	file := writeTestFile(t, src)
+	start := strings.Index(src, "foo")
+
+	result, err := Apply([]rules.Violation{replace(file, start, start+3, "{{")})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if len(result.Files) != 0 {
+		t.Errorf("Expected no changed files for invalid Go, got %d", len(result.Files))
+	}
+	if len(result.Skipped) != 1 {
+		t.Errorf("Expected 1 skipped violation, got %d", len(result.Skipped))
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	original := "a\nb\nc\n"
+	fixed := "a\nB\nc\n"
+
+	want := "--- f.go\n+++ f.go\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"
+	if got := UnifiedDiff("f.go", []byte(original), []byte(fixed)); got != want {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestUnifiedDiff_Identical(t *testing.T) {
+	if got := UnifiedDiff("f.go", []byte("a\n"), []byte("a\n")); got != "" {
+		t.Errorf("Expected empty diff, got %q", got)
+	}
+}
+
+func TestDiffLines_ShortestEditScript(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"", ""},
+		{"", "a\nb\n"},
+		{"a\nb\n", ""},
+		{"a\nb\nc\n", "x\ny\n"},
+		{"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n"},
+		{"a\nb\nc\nd\n", "a\nc\nd\ne\n"},
+	}
+
+	for _, tt := range tests {
+		a, b := splitLines(tt.a), splitLines(tt.b)
+		ops := diffLines(a, b)
+
+		var oldLines, newLines []string
+		edits := 0
+		for _, o := range ops {
+			if o.kind != opInsert {
+				oldLines = append(oldLines, o.line)
+			}
+			if o.kind != opDelete {
+				newLines = append(newLines, o.line)
+			}
+			if o.kind != opEqual {
+				edits++
+			}
+		}
+		if strings.Join(oldLines, "") != tt.a || strings.Join(newLines, "") != tt.b {
+			t.Errorf("%q -> %q: edit script %v doesn't transform one into the other", tt.a, tt.b, ops)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+			t.Errorf("%q -> %q: expected %d edits, got %d", tt.a, tt.b, want, edits)
+		}
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/Arneball/goasted/rules"
 )
@@ -22,13 +23,29 @@ func (f TextFormatter) Format(violations []rules.Violation, w io.Writer) error {
 		return nil
 	}
 
-	_, _ = fmt.Fprintf(w, "Found %d violation(s):\n\n", len(violations))
+	counts := make(map[rules.Severity]int)
 	for _, v := range violations {
-		_, _ = fmt.Fprintf(w, "%s:%d:%d: [%s] %s\n", v.File, v.Line, v.Column, v.Rule, v.Message)
+		counts[v.Severity]++
+	}
+
+	_, _ = fmt.Fprintf(w, "Found %d violation(s) (%d error(s), %d warning(s), %d info):\n\n",
+		len(violations), counts[rules.SeverityError], counts[rules.SeverityWarning], counts[rules.SeverityInfo])
+	for _, v := range violations {
+		_, _ = fmt.Fprintln(w, FormatLine(v))
 	}
 	return nil
 }
 
+// FormatLine renders a violation as file:line:column: severity: [rule] message,
+// followed by the build configurations it was found in, if any
+func FormatLine(v rules.Violation) string {
+	line := fmt.Sprintf("%s:%d:%d: %s: [%s] %s", v.File, v.Line, v.Column, v.Severity, v.Rule, v.Message)
+	if len(v.Configurations) > 0 {
+		line += " (" + strings.Join(v.Configurations, ", ") + ")"
+	}
+	return line
+}
+
 // JUnitFormatter formats violations as JUnit XML
 type JUnitFormatter struct{}
 
@@ -52,6 +69,7 @@ type JUnitTestCase struct {
 	Classname string        `xml:"classname,attr"`
 	Time      string        `xml:"time,attr"`
 	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
 }
 
 type JUnitFailure struct {
@@ -60,23 +78,30 @@ type JUnitFailure struct {
 	Content string `xml:",chardata"`
 }
 
+// Format renders error violations as failed test cases. Warnings and info
+// violations become passing test cases with the finding in system-out, so
+// they show up in CI reports without failing them.
 func (f JUnitFormatter) Format(violations []rules.Violation, w io.Writer) error {
-	// Group violations by file
+	// Group violations by file, keeping the order files first appear in
 	fileViolations := make(map[string][]rules.Violation)
+	var files []string
 	for _, v := range violations {
+		if _, ok := fileViolations[v.File]; !ok {
+			files = append(files, v.File)
+		}
 		fileViolations[v.File] = append(fileViolations[v.File], v)
 	}
 
 	// Create test suites
 	var suites []JUnitTestSuite
 
-	for file, viols := range fileViolations {
+	for _, file := range files {
+		viols := fileViolations[file]
 		suite := JUnitTestSuite{
-			Name:     file,
-			Tests:    len(viols),
-			Failures: len(viols),
-			Errors:   0,
-			Time:     "0",
+			Name:   file,
+			Tests:  len(viols),
+			Errors: 0,
+			Time:   "0",
 		}
 
 		for _, v := range viols {
@@ -84,11 +109,16 @@ func (f JUnitFormatter) Format(violations []rules.Violation, w io.Writer) error
 				Name:      fmt.Sprintf("%s (line %d)", v.Rule, v.Line),
 				Classname: file,
 				Time:      "0",
-				Failure: &JUnitFailure{
+			}
+			if v.Severity == rules.SeverityError {
+				suite.Failures++
+				testCase.Failure = &JUnitFailure{
 					Message: v.Message,
 					Type:    v.Rule,
-					Content: fmt.Sprintf("%s:%d:%d: [%s] %s", v.File, v.Line, v.Column, v.Rule, v.Message),
-				},
+					Content: FormatLine(v),
+				}
+			} else {
+				testCase.SystemOut = FormatLine(v)
 			}
 			suite.Cases = append(suite.Cases, testCase)
 		}
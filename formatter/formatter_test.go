@@ -0,0 +1,161 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+var testViolations = []rules.Violation{
+	{File: "a.go", Line: 3, Column: 2, Rule: "sql-context-required", Message: "Use ExecContext instead of Exec", Severity: rules.SeverityError},
+	{File: "a.go", Line: 7, Column: 1, Rule: "gokit-usage", Message: "File imports go-kit package", Severity: rules.SeverityWarning},
+}
+
+func TestTextFormatter_ShowsSeverity(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (TextFormatter{}).Format(testViolations, &buf); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "(1 error(s), 1 warning(s), 0 info)") {
+		t.Errorf("Expected severity summary, got:\n%s", out)
+	}
+	if !strings.Contains(out, "a.go:3:2: error: [sql-context-required]") {
+		t.Errorf("Expected error line, got:\n%s", out)
+	}
+	if !strings.Contains(out, "a.go:7:1: warning: [gokit-usage]") {
+		t.Errorf("Expected warning line, got:\n%s", out)
+	}
+}
+
+func TestJUnitFormatter_OnlyErrorsFail(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JUnitFormatter{}).Format(testViolations, &buf); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `tests="2" failures="1"`) {
+		t.Errorf("Expected 2 tests with 1 failure, got:\n%s", out)
+	}
+	if strings.Count(out, "<failure") != 1 {
+		t.Errorf("Expected exactly 1 failure element, got:\n%s", out)
+	}
+	if !strings.Contains(out, "<system-out>a.go:7:1: warning: [gokit-usage]") {
+		t.Errorf("Expected warning in system-out, got:\n%s", out)
+	}
+}
+
+func TestSARIFFormatter_Levels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (SARIFFormatter{Registry: rules.DefaultRegistry()}).Format(testViolations, &buf); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 {
+		t.Fatalf("Expected 1 run with 2 results, got %+v", log.Runs)
+	}
+	results := log.Runs[0].Results
+	if results[0].Level != "error" || results[1].Level != "warning" {
+		t.Errorf("Expected levels error and warning, got %s and %s", results[0].Level, results[1].Level)
+	}
+	if len(log.Runs[0].Tool.Driver.Rules) != 4 {
+		t.Errorf("Expected 4 rules in driver, got %d", len(log.Runs[0].Tool.Driver.Rules))
+	}
+}
+
+func TestSARIFFormatter_Locations(t *testing.T) {
+	base := t.TempDir()
+	outside := t.TempDir()
+	violations := []rules.Violation{
+		{File: filepath.Join(base, "pkg", "a b.go"), Line: 3, Column: 2, Rule: "gokit-usage", Message: "m"},
+		{File: filepath.Join(outside, "load.go"), Rule: "load-error", Message: "m"},
+		{Rule: "internal-error", Message: "m"},
+	}
+
+	var buf bytes.Buffer
+	if err := (SARIFFormatter{BaseDir: base}).Format(violations, &buf); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if strings.Contains(buf.String(), `"startLine": 0`) {
+		t.Errorf("Expected no region without a line, got %s", buf.String())
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	run := log.Runs[0]
+	if got := run.OriginalURIBaseIDs[sarifBaseID].URI; got != fileURI(base)+"/" {
+		t.Errorf("Expected %s to be based at %s, got %s", sarifBaseID, fileURI(base)+"/", got)
+	}
+
+	inside := run.Results[0].Locations[0].PhysicalLocation
+	if inside.ArtifactLocation != (sarifArtifactLocation{URI: "pkg/a%20b.go", URIBaseID: sarifBaseID}) {
+		t.Errorf("Expected a URI relative to the base directory, got %+v", inside.ArtifactLocation)
+	}
+	if inside.Region == nil || inside.Region.StartLine != 3 {
+		t.Errorf("Expected a region at line 3, got %+v", inside.Region)
+	}
+
+	other := run.Results[1].Locations[0].PhysicalLocation
+	if other.ArtifactLocation.URI != fileURI(filepath.Join(outside, "load.go")) || other.ArtifactLocation.URIBaseID != "" {
+		t.Errorf("Expected an absolute file URI outside the base directory, got %+v", other.ArtifactLocation)
+	}
+	if other.Region != nil {
+		t.Errorf("Expected no region without a line, got %+v", other.Region)
+	}
+	if !strings.HasPrefix(other.ArtifactLocation.URI, "file:///") {
+		t.Errorf("Expected a file URI, got %s", other.ArtifactLocation.URI)
+	}
+
+	if len(run.Results[2].Locations) != 0 {
+		t.Errorf("Expected no location without a file, got %+v", run.Results[2].Locations)
+	}
+}
+
+func TestJUnitFormatter_StableSuiteOrder(t *testing.T) {
+	violations := []rules.Violation{
+		{File: "a.go", Line: 1, Rule: "r", Severity: rules.SeverityError},
+		{File: "b.go", Line: 1, Rule: "r", Severity: rules.SeverityError},
+		{File: "c.go", Line: 1, Rule: "r", Severity: rules.SeverityError},
+	}
+
+	var first bytes.Buffer
+	if err := (JUnitFormatter{}).Format(violations, &first); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		if err := (JUnitFormatter{}).Format(violations, &buf); err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		if buf.String() != first.String() {
+			t.Fatalf("Expected identical output between runs, got:\n%s\nand:\n%s", first.String(), buf.String())
+		}
+	}
+}
+
+func TestTextFormatter_ShowsConfigurations(t *testing.T) {
+	violations := []rules.Violation{
+		{File: "a.go", Line: 1, Column: 1, Rule: "r", Message: "m", Severity: rules.SeverityError, Configurations: []string{"linux/amd64", "windows/arm64"}},
+	}
+
+	var buf bytes.Buffer
+	if err := (TextFormatter{}).Format(violations, &buf); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "a.go:1:1: error: [r] m (linux/amd64, windows/arm64)") {
+		t.Errorf("Expected configurations in output, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,218 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// SARIFFormatter formats violations as a SARIF 2.1.0 log, as consumed by
+// GitHub code scanning and most other static analysis dashboards
+type SARIFFormatter struct {
+	// Registry is used to describe the rules in the log. It may be nil.
+	Registry *rules.Registry
+
+	// BaseDir is the directory file locations are relative to, usually the
+	// repository root. Files outside it, or all files if it's empty, are
+	// located by absolute file URIs.
+	BaseDir string
+}
+
+// sarifBaseID names BaseDir in the log
+const sarifBaseID = "%SRCROOT%"
+
+// SARIF log structures
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     *sarifMessage      `json:"shortDescription,omitempty"`
+	DefaultConfiguration *sarifRuleDefaults `json:"defaultConfiguration,omitempty"`
+}
+
+type sarifRuleDefaults struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string           `json:"ruleId"`
+	Level      string           `json:"level"`
+	Message    sarifMessage     `json:"message"`
+	Locations  []sarifLocation  `json:"locations"`
+	Properties *sarifProperties `json:"properties,omitempty"`
+}
+
+type sarifProperties struct {
+	Configurations []string `json:"configurations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarifLevel maps a severity to a SARIF result level
+func sarifLevel(s rules.Severity) string {
+	switch s {
+	case rules.SeverityInfo:
+		return "note"
+	case rules.SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+func (f SARIFFormatter) Format(violations []rules.Violation, w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "goasted",
+			InformationURI: "https://github.com/Arneball/goasted",
+		}},
+		Results: []sarifResult{},
+	}
+	base := ""
+	if f.BaseDir != "" {
+		if abs, err := filepath.Abs(f.BaseDir); err == nil {
+			base = abs
+			run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+				sarifBaseID: {URI: fileURI(abs) + "/"},
+			}
+		}
+	}
+
+	// Describe every rule that is registered or that produced a violation
+	ruleIDs := make(map[string]bool)
+	if f.Registry != nil {
+		for _, rule := range f.Registry.GetCheckers() {
+			ruleIDs[rule.Name()] = true
+		}
+	}
+	for _, v := range violations {
+		ruleIDs[v.Rule] = true
+	}
+	ids := make([]string, 0, len(ruleIDs))
+	for id := range ruleIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		sr := sarifRule{ID: id}
+		if f.Registry != nil {
+			if rule := f.Registry.GetChecker(id); rule != nil {
+				sr.ShortDescription = &sarifMessage{Text: rule.Description()}
+				sr.DefaultConfiguration = &sarifRuleDefaults{Level: sarifLevel(rule.DefaultSeverity())}
+			}
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sr)
+	}
+
+	for _, v := range violations {
+		var properties *sarifProperties
+		if len(v.Configurations) > 0 {
+			properties = &sarifProperties{Configurations: v.Configurations}
+		}
+		// Violations without a file, such as some internal errors, have no
+		// location, and those without a line no region: SARIF lines start
+		// at 1
+		locations := []sarifLocation{}
+		if v.File != "" {
+			location := sarifPhysicalLocation{ArtifactLocation: artifactLocation(base, v.File)}
+			if v.Line > 0 {
+				location.Region = &sarifRegion{StartLine: v.Line, StartColumn: v.Column}
+			}
+			locations = append(locations, sarifLocation{PhysicalLocation: location})
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:     v.Rule,
+			Level:      sarifLevel(v.Severity),
+			Message:    sarifMessage{Text: v.Message},
+			Locations:  locations,
+			Properties: properties,
+		})
+	}
+
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+
+	output, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SARIF: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "%s\n", output)
+	return nil
+}
+
+// artifactLocation locates file relative to base, or by its absolute file
+// URI if it's outside base or base is empty
+func artifactLocation(base, file string) sarifArtifactLocation {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+	if base != "" {
+		if rel, err := filepath.Rel(base, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return sarifArtifactLocation{URI: (&url.URL{Path: filepath.ToSlash(rel)}).String(), URIBaseID: sarifBaseID}
+		}
+	}
+	return sarifArtifactLocation{URI: fileURI(abs)}
+}
+
+// fileURI returns the file URI of an absolute path
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// A Windows drive letter
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -1,10 +1,11 @@
 module github.com/Arneball/goasted
 
-go 1.25
-
-require golang.org/x/tools v0.38.0
+go 1.25.0
 
 require (
-	golang.org/x/mod v0.29.0 // indirect
-	golang.org/x/sync v0.18.0 // indirect
+	golang.org/x/mod v0.35.0
+	golang.org/x/tools v0.44.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sync v0.20.0 // indirect
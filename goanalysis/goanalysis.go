@@ -0,0 +1,160 @@
+// Package goanalysis exposes goasted rules as golang.org/x/tools/go/analysis
+// analyzers so they can run inside golangci-lint, gopls and go vet -vettool.
+package goanalysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// NewAnalyzer wraps a single rule, a rules.Rule, rules.PackageRule or
+// rules.SSARule, as an analysis.Analyzer
+func NewAnalyzer(rule rules.Checker) *analysis.Analyzer {
+	var requires []*analysis.Analyzer
+	if _, ok := rule.(rules.SSARule); ok {
+		requires = append(requires, buildssa.Analyzer)
+	}
+
+	return &analysis.Analyzer{
+		Name:     AnalyzerName(rule.Name()),
+		Requires: requires,
+		Doc:      rule.Description(),
+		Run: func(pass *analysis.Pass) (any, error) {
+			files := make(map[string]*ast.File, len(pass.Files))
+			filenames := make([]string, 0, len(pass.Files))
+			var violations []rules.Violation
+
+			for _, file := range pass.Files {
+				filename := pass.Fset.File(file.Pos()).Name()
+				files[filename] = file
+				filenames = append(filenames, filename)
+			}
+
+			if fileRule, ok := rule.(rules.Rule); ok {
+				for i, file := range pass.Files {
+					violations = append(violations, fileRule.Check(&rules.Context{
+						FileSet:      pass.Fset,
+						File:         file,
+						Filename:     filenames[i],
+						TypeInfo:     pass.TypesInfo,
+						Package:      pass.Pkg,
+						PackageFiles: filenames,
+					})...)
+				}
+			}
+
+			if pkgRule, ok := rule.(rules.PackageRule); ok {
+				ctx := &rules.PackageContext{
+					FileSet:   pass.Fset,
+					Files:     pass.Files,
+					Package:   pass.Pkg,
+					TypesInfo: pass.TypesInfo,
+					Imports:   importGraph(pass.Pkg),
+					Filenames: filenames,
+				}
+				violations = append(violations, pkgRule.CheckPackage(ctx)...)
+			}
+
+			if ssaRule, ok := rule.(rules.SSARule); ok {
+				built := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+				violations = append(violations, ssaRule.CheckSSA(&rules.SSAContext{
+					FileSet:   pass.Fset,
+					Program:   built.Pkg.Prog,
+					Package:   built.Pkg,
+					Functions: built.SrcFuncs,
+				})...)
+			}
+
+			for _, v := range violations {
+				if file, ok := files[v.File]; ok {
+					pass.Report(toDiagnostic(pass.Fset, file, v))
+				}
+			}
+			return nil, nil
+		},
+	}
+}
+
+// importGraph builds the import graph of pkg and its dependencies from type
+// information, as the analysis driver doesn't expose packages.Load results
+func importGraph(pkg *types.Package) map[string][]string {
+	graph := make(map[string][]string)
+	var visit func(p *types.Package)
+	visit = func(p *types.Package) {
+		if _, ok := graph[p.Path()]; ok {
+			return
+		}
+		imports := make([]string, 0, len(p.Imports()))
+		graph[p.Path()] = imports
+		for _, imp := range p.Imports() {
+			imports = append(imports, imp.Path())
+			visit(imp)
+		}
+		sort.Strings(imports)
+		graph[p.Path()] = imports
+	}
+	visit(pkg)
+	return graph
+}
+
+// Analyzers wraps every rule in the registry as an analysis.Analyzer
+func Analyzers(registry *rules.Registry) []*analysis.Analyzer {
+	var analyzers []*analysis.Analyzer
+	for _, rule := range registry.GetCheckers() {
+		analyzers = append(analyzers, NewAnalyzer(rule))
+	}
+	return analyzers
+}
+
+// AnalyzerName converts a rule name such as "sql-context-required" into a
+// valid analyzer name ("sql_context_required"), since analyzer names must be
+// Go identifiers
+func AnalyzerName(ruleName string) string {
+	return strings.ReplaceAll(ruleName, "-", "_")
+}
+
+// toDiagnostic converts a violation into a diagnostic positioned in file
+func toDiagnostic(fset *token.FileSet, file *ast.File, v rules.Violation) analysis.Diagnostic {
+	tf := fset.File(file.Pos())
+	diagnostic := analysis.Diagnostic{
+		Pos:      violationPos(tf, v),
+		Category: v.Rule,
+		Message:  v.Message,
+	}
+	for _, fix := range v.SuggestedFixes {
+		suggested := analysis.SuggestedFix{Message: fix.Message}
+		for _, edit := range fix.Edits {
+			suggested.TextEdits = append(suggested.TextEdits, analysis.TextEdit{
+				Pos:     tf.Pos(edit.Start),
+				End:     tf.Pos(edit.End),
+				NewText: []byte(edit.NewText),
+			})
+		}
+		diagnostic.SuggestedFixes = append(diagnostic.SuggestedFixes, suggested)
+	}
+	return diagnostic
+}
+
+// violationPos maps a violation's line and column back to a token.Pos
+func violationPos(tf *token.File, v rules.Violation) token.Pos {
+	if v.Line < 1 || v.Line > tf.LineCount() {
+		return tf.Pos(0)
+	}
+	pos := tf.LineStart(v.Line)
+	if v.Column > 1 {
+		offset := tf.Offset(pos) + v.Column - 1
+		if offset > tf.Size() {
+			offset = tf.Size()
+		}
+		pos = tf.Pos(offset)
+	}
+	return pos
+}
@@ -0,0 +1,30 @@
+package goanalysis
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func TestAnalyzers_AreValid(t *testing.T) {
+	if err := analysis.Validate(Analyzers(rules.DefaultRegistry())); err != nil {
+		t.Fatalf("Analyzers are not valid: %v", err)
+	}
+}
+
+func TestAnalyzerName(t *testing.T) {
+	if got := AnalyzerName("sql-context-required"); got != "sql_context_required" {
+		t.Errorf("Expected sql_context_required, got %s", got)
+	}
+}
+
+func TestSqlContextAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), NewAnalyzer(rules.NewSqlContextRule()), "sqlctx")
+}
+
+func TestGokitAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), NewAnalyzer(rules.NewGokitRule()), "gokit")
+}
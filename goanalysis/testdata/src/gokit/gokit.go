@@ -0,0 +1,5 @@
+package gokit
+
+import (
+	_ "github.com/go-kit/kit/endpoint" // want `File imports go-kit package: github.com/go-kit/kit/endpoint`
+)
@@ -0,0 +1,9 @@
+package sqlctx
+
+import "database/sql"
+
+func bad(db *sql.DB) {
+	db.Exec("UPDATE users SET active = true") // want `Use ExecContext instead of Exec`
+	tx, _ := db.Begin()                       // want `Use BeginTx instead of Begin`
+	tx.Query("SELECT 1")                      // want `Use QueryContext instead of Query`
+}
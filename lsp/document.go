@@ -0,0 +1,93 @@
+package lsp
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document converts between the byte offsets and line:column positions of
+// goasted and the positions of LSP in one file's content
+type document struct {
+	content []byte
+
+	// lines holds the offset of the start of each line
+	lines []int
+}
+
+// newDocument indexes the lines of content
+func newDocument(content []byte) *document {
+	d := &document{content: content, lines: []int{0}}
+	for i, b := range content {
+		if b == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d
+}
+
+// offset returns the byte offset of a 1-based line and byte column, as
+// violations report them. Column 0 means the start of the line.
+func (d *document) offset(line, column int) int {
+	if line < 1 {
+		return 0
+	}
+	if line > len(d.lines) {
+		return len(d.content)
+	}
+	offset := d.lines[line-1]
+	if column > 1 {
+		offset += column - 1
+	}
+	return min(offset, d.lineEnd(line-1))
+}
+
+// lineEnd returns the offset of the end of a 0-based line, before its
+// newline
+func (d *document) lineEnd(line int) int {
+	if line+1 < len(d.lines) {
+		return d.lines[line+1] - 1
+	}
+	return len(d.content)
+}
+
+// position converts a byte offset to an LSP position
+func (d *document) position(offset int) position {
+	offset = max(0, min(offset, len(d.content)))
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+
+	character := 0
+	for _, r := range string(d.content[d.lines[line]:offset]) {
+		if n := utf16.RuneLen(r); n > 0 {
+			character += n
+		} else {
+			character++
+		}
+	}
+	return position{Line: line, Character: character}
+}
+
+// wordSpan returns the range of the identifier or other token starting at
+// offset, so that diagnostics underline more than a single character. At
+// a line start, for violations without a column, it spans the whole line.
+func (d *document) wordSpan(offset int, wholeLine bool) span {
+	line := d.position(offset).Line
+	end := offset
+	if wholeLine {
+		end = d.lineEnd(line)
+	} else {
+		for end < d.lineEnd(line) {
+			r, size := utf8.DecodeRune(d.content[end:])
+			if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			end += size
+		}
+		if end == offset && end < d.lineEnd(line) {
+			_, size := utf8.DecodeRune(d.content[end:])
+			end += size
+		}
+	}
+	return span{Start: d.position(offset), End: d.position(end)}
+}
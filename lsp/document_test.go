@@ -0,0 +1,47 @@
+package lsp
+
+import "testing"
+
+func TestDocument_Position(t *testing.T) {
+	doc := newDocument([]byte("package p\n\n// héllo 😀 x\nvar x = 1\n"))
+
+	tests := []struct {
+		offset int
+		want   position
+	}{
+		{0, position{0, 0}},
+		{8, position{0, 8}},
+		{10, position{1, 0}},
+		// é is 2 bytes and 1 UTF-16 unit, 😀 4 bytes and 2 units
+		{17, position{2, 5}},
+		{25, position{2, 11}},
+		{100, position{4, 0}},
+	}
+	for _, tt := range tests {
+		if got := doc.position(tt.offset); got != tt.want {
+			t.Errorf("Expected offset %d at %+v, got %+v", tt.offset, tt.want, got)
+		}
+	}
+}
+
+func TestDocument_WordSpan(t *testing.T) {
+	doc := newDocument([]byte("package p\n\nfunc f() { db.Query(q) }\n"))
+
+	got := doc.wordSpan(doc.offset(3, 12), false)
+	want := span{Start: position{2, 11}, End: position{2, 13}}
+	if got != want {
+		t.Errorf("Expected the identifier at line 3 column 12 to span %+v, got %+v", want, got)
+	}
+
+	got = doc.wordSpan(doc.offset(3, 14), false)
+	want = span{Start: position{2, 13}, End: position{2, 14}}
+	if got != want {
+		t.Errorf("Expected punctuation to span one character %+v, got %+v", want, got)
+	}
+
+	got = doc.wordSpan(doc.offset(3, 0), true)
+	want = span{Start: position{2, 0}, End: position{2, 24}}
+	if got != want {
+		t.Errorf("Expected a violation without column to span the line %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+)
+
+// message is an incoming request, notification or response. Requests and
+// responses have an ID, notifications don't; responses have no method.
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+// response answers a request with a result or an error
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// outgoing is a request or notification sent to the client
+type outgoing struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// rpcError is the error of a failed request
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes JSON-RPC 2.0 messages framed by Content-Length
+// headers, as LSP does over stdio
+type conn struct {
+	r *bufio.Reader
+
+	mu     sync.Mutex
+	w      io.Writer
+	lastID int
+}
+
+// newConn creates a connection reading from r and writing to w
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: bufio.NewReader(r), w: w}
+}
+
+// read reads the next message. It returns io.EOF when the client closed
+// the stream between messages.
+func (c *conn) read() (*message, error) {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read message header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &rpcError{Code: codeParseError, Message: fmt.Sprintf("failed to parse message: %v", err)}
+	}
+	return &msg, nil
+}
+
+// reply answers the request with id. A nil result is sent as null.
+func (c *conn) reply(id json.RawMessage, result any, rpcErr *rpcError) error {
+	resp := response{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+		resp.Result = data
+	}
+	return c.write(resp)
+}
+
+// notify sends a notification to the client
+func (c *conn) notify(method string, params any) error {
+	return c.write(outgoing{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// call sends a request to the client. Its response is read like any other
+// message and ignored.
+func (c *conn) call(method string, params any) error {
+	c.mu.Lock()
+	c.lastID++
+	id := c.lastID
+	c.mu.Unlock()
+	return c.write(outgoing{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+}
+
+// write frames and sends one message
+func (c *conn) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if _, err := c.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
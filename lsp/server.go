@@ -0,0 +1,633 @@
+// Package lsp implements a Language Server Protocol server publishing
+// goasted violations as diagnostics.
+//
+// Open files are analyzed when they are opened and saved, together with the
+// other files of their packages. Analysis goes through an analyzer.Session,
+// so that packages and dependencies unchanged since the previous analysis
+// are neither loaded nor type-checked again. Suggested fixes are offered as
+// quick fixes, and hovering a diagnostic shows the documentation of its rule
+// as "goasted explain" prints it.
+//
+// Serve handles messages one at a time, in the order they arrive: a
+// notification or request is only read once the previous one is answered,
+// so a slow analysis delays everything after it. "$/cancelRequest" is
+// ignored, as no request is ever pending when it is read.
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Arneball/goasted/analyzer"
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// source names goasted as the origin of diagnostics
+const source = "goasted"
+
+// Workspace is what a workspace is analyzed with
+type Workspace struct {
+	// Analyzer analyzes the workspace. Its rules are explained on hover.
+	Analyzer *analyzer.Analyzer
+
+	// Close, if set, releases the workspace's resources, such as plugin
+	// processes
+	Close func()
+}
+
+// Config configures a Server
+type Config struct {
+	// Open sets up the analysis of the workspace rooted at root. It is
+	// called again when a configuration file changes.
+	Open func(root string) (*Workspace, error)
+}
+
+// Server is a language server for one client
+type Server struct {
+	cfg  Config
+	conn *conn
+
+	root           string
+	watchFiles     bool
+	workspace      *Workspace
+	session        *analyzer.Session
+	shutdownCalled bool
+
+	// open holds the open Go files
+	open map[string]bool
+
+	// published holds the violations last published for each open file
+	published map[string]*published
+
+	// stamps holds the size and modification time of each changed file
+	// when the session last learned about it
+	stamps map[string]stamp
+}
+
+// published is the state of a file's diagnostics, kept to answer code
+// action and hover requests
+type published struct {
+	doc   *document
+	items []item
+}
+
+// item is a violation and the diagnostic it was published as
+type item struct {
+	violation  rules.Violation
+	diagnostic diagnostic
+}
+
+// stamp tells a changed file apart
+type stamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// errExitWithoutShutdown is returned by Serve when the client exits
+// without shutting the server down first
+var errExitWithoutShutdown = errors.New("exit without shutdown")
+
+// NewServer creates a server
+func NewServer(cfg Config) *Server {
+	return &Server{
+		cfg:       cfg,
+		open:      make(map[string]bool),
+		published: make(map[string]*published),
+		stamps:    make(map[string]stamp),
+	}
+}
+
+// Serve answers the messages of a client read from r, writing to w, until
+// the client exits or closes r. It returns an error if the client exits
+// without shutting the server down, as LSP requires a failure exit code
+// then.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+	defer s.closeWorkspace()
+
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			s.log(messageError, err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case msg.Method == "":
+			// A response to a request of the server
+			continue
+		case msg.Method == "exit":
+			if !s.shutdownCalled {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, err := s.handle(ctx, msg)
+		if len(msg.ID) == 0 {
+			if err != nil {
+				s.log(messageError, err.Error())
+			}
+			continue
+		}
+		var replyErr *rpcError
+		if err != nil && !errors.As(err, &replyErr) {
+			replyErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		if err := s.conn.reply(msg.ID, result, replyErr); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches a request or notification
+func (s *Server) handle(ctx context.Context, msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params initializeParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params), nil
+
+	case "initialized":
+		return nil, s.registerWatchers()
+
+	case "shutdown":
+		s.shutdownCalled = true
+		s.closeWorkspace()
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if filename, ok := goFile(params.TextDocument.URI); ok {
+			s.open[filename] = true
+			// The file may have changed on disk while it was closed
+			s.refresh([]string{filename})
+			s.analyze(ctx, []string{filename})
+		}
+		return nil, nil
+
+	case "textDocument/didSave":
+		var params didSaveTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if filename, ok := fileOf(params.TextDocument.URI); ok {
+			s.refresh([]string{filename})
+			s.analyze(ctx, s.openFiles())
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params didCloseTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if filename, ok := goFile(params.TextDocument.URI); ok && s.open[filename] {
+			delete(s.open, filename)
+			delete(s.published, filename)
+			return nil, s.publish(filename, nil)
+		}
+		return nil, nil
+
+	case "workspace/didChangeWatchedFiles":
+		var params didChangeWatchedFilesParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		var filenames []string
+		for _, change := range params.Changes {
+			if filename, ok := fileOf(change.URI); ok {
+				filenames = append(filenames, filename)
+			}
+		}
+		if s.refresh(filenames) {
+			s.analyze(ctx, s.openFiles())
+		}
+		return nil, nil
+
+	case "textDocument/codeAction":
+		var params codeActionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.codeActions(params), nil
+
+	case "textDocument/hover":
+		var params hoverParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	}
+
+	if len(msg.ID) > 0 {
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+	// Other notifications, such as $/cancelRequest, are ignored
+	return nil, nil
+}
+
+// decode decodes the parameters of a message
+func decode(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
+
+// initialize sets up the workspace and declares what the server supports
+func (s *Server) initialize(params initializeParams) initializeResult {
+	s.root = "."
+	if dir, ok := fileOf(params.RootURI); ok {
+		s.root = dir
+	} else if len(params.WorkspaceFolders) > 0 {
+		if dir, ok := fileOf(params.WorkspaceFolders[0].URI); ok {
+			s.root = dir
+		}
+	}
+	s.watchFiles = params.Capabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration
+	s.openWorkspace()
+
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync: textDocumentSyncOptions{
+				OpenClose: true,
+				Change:    syncNone,
+				Save:      saveOptions{},
+			},
+			CodeActionProvider: codeActionOptions{CodeActionKinds: []string{kindQuickFix}},
+			HoverProvider:      true,
+		},
+		ServerInfo: serverInfo{Name: source},
+	}
+}
+
+// registerWatchers asks the client to report changes to the files that
+// affect the analysis, if it can, so that edits made outside the editor
+// are noticed too
+func (s *Server) registerWatchers() error {
+	if !s.watchFiles {
+		return nil
+	}
+	watchers := []fileSystemWatcher{
+		{GlobPattern: "**/*.go"},
+		{GlobPattern: "**/{go.mod,go.sum,go.work,go.work.sum}"},
+	}
+	for _, name := range config.FileNames {
+		watchers = append(watchers, fileSystemWatcher{GlobPattern: "**/" + name})
+	}
+	return s.conn.call("client/registerCapability", registrationParams{
+		Registrations: []registration{{
+			ID:              "goasted-watched-files",
+			Method:          "workspace/didChangeWatchedFiles",
+			RegisterOptions: didChangeWatchedFilesRegistrationOptions{Watchers: watchers},
+		}},
+	})
+}
+
+// openWorkspace sets up the analysis of the workspace, replacing the
+// previous one. Failures are shown to the user, and leave the server
+// without analysis until the configuration is fixed.
+func (s *Server) openWorkspace() {
+	s.closeWorkspace()
+	ws, err := s.cfg.Open(s.root)
+	if err != nil {
+		s.show(messageError, fmt.Sprintf("goasted: %v", err))
+		return
+	}
+	s.workspace = ws
+	s.session = analyzer.NewSession(ws.Analyzer)
+}
+
+// closeWorkspace releases the current workspace, if any
+func (s *Server) closeWorkspace() {
+	if s.workspace != nil && s.workspace.Close != nil {
+		s.workspace.Close()
+	}
+	s.workspace = nil
+	s.session = nil
+}
+
+// refresh tells the session about changed files and reports whether any
+// had changed since the session last learned about them. An editor saving
+// a file and a file watcher reporting it are thus handled once.
+func (s *Server) refresh(filenames []string) bool {
+	var changed []string
+	reopen, reset := false, false
+	for _, filename := range filenames {
+		st := stampOf(filename)
+		if prev, ok := s.stamps[filename]; ok && prev == st {
+			continue
+		}
+		s.stamps[filename] = st
+
+		switch name := filepath.Base(filename); {
+		case slices.Contains(config.FileNames, name):
+			reopen = true
+		case name == "go.mod" || name == "go.sum" || name == "go.work" || name == "go.work.sum":
+			reset = true
+		case strings.HasSuffix(name, ".go"):
+			changed = append(changed, filename)
+		}
+	}
+
+	switch {
+	case reopen:
+		s.openWorkspace()
+	case s.session == nil:
+	case reset:
+		s.session.Reset()
+	default:
+		s.session.Invalidate(changed...)
+	}
+	return reopen || reset || len(changed) > 0
+}
+
+// stampOf returns the current stamp of a file
+func stampOf(filename string) stamp {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return stamp{}
+	}
+	return stamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// openFiles returns the open Go files, sorted
+func (s *Server) openFiles() []string {
+	filenames := make([]string, 0, len(s.open))
+	for filename := range s.open {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// analyze analyzes the packages of filenames, one directory at a time so
+// that a package failing to load doesn't hold back the others, and
+// publishes the diagnostics of the open files among them
+func (s *Server) analyze(ctx context.Context, filenames []string) {
+	if s.session == nil {
+		return
+	}
+
+	byDir := make(map[string][]string)
+	var dirs []string
+	for _, filename := range filenames {
+		dir := filepath.Dir(filename)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], filename)
+	}
+
+	for _, dir := range dirs {
+		result, err := s.session.AnalyzeFiles(ctx, byDir[dir])
+		if err != nil {
+			s.log(messageError, fmt.Sprintf("goasted: %v", err))
+			continue
+		}
+
+		byFile := make(map[string][]rules.Violation)
+		for _, v := range result.Violations {
+			byFile[v.File] = append(byFile[v.File], v)
+		}
+		for _, filename := range byDir[dir] {
+			if !s.open[filename] {
+				continue
+			}
+			if err := s.publish(filename, byFile[filename]); err != nil {
+				s.log(messageError, err.Error())
+			}
+		}
+	}
+}
+
+// publish sends the diagnostics of a file and remembers its violations
+func (s *Server) publish(filename string, violations []rules.Violation) error {
+	params := publishDiagnosticsParams{URI: uriOf(filename), Diagnostics: []diagnostic{}}
+	if len(violations) > 0 {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		p := &published{doc: newDocument(content)}
+		for _, v := range violations {
+			d := s.toDiagnostic(p.doc, v)
+			p.items = append(p.items, item{violation: v, diagnostic: d})
+			params.Diagnostics = append(params.Diagnostics, d)
+		}
+		s.published[filename] = p
+	} else {
+		delete(s.published, filename)
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", params)
+}
+
+// toDiagnostic converts a violation into a diagnostic
+func (s *Server) toDiagnostic(doc *document, v rules.Violation) diagnostic {
+	d := diagnostic{
+		Range:    doc.wordSpan(doc.offset(v.Line, v.Column), v.Column == 0),
+		Severity: severityOf(v.Severity),
+		Code:     v.Rule,
+		Source:   source,
+		Message:  v.Message,
+	}
+	if rule := s.rule(v.Rule, v.File); rule != nil {
+		if url := rules.DocOf(rule).URL(); url != "" {
+			d.CodeDescription = &codeDescription{Href: url}
+		}
+	}
+	return d
+}
+
+// severityOf maps a violation severity to a diagnostic severity
+func severityOf(severity rules.Severity) int {
+	switch severity {
+	case rules.SeverityError:
+		return severityError
+	case rules.SeverityInfo:
+		return severityInformation
+	default:
+		return severityWarning
+	}
+}
+
+// rule returns the rule with the given name that may run on filename, or
+// nil
+func (s *Server) rule(name, filename string) rules.Checker {
+	if s.workspace == nil {
+		return nil
+	}
+	registry, err := s.workspace.Analyzer.RegistryFor(filepath.Dir(filename))
+	if err != nil {
+		return nil
+	}
+	return registry.GetChecker(name)
+}
+
+// codeActions returns a quick fix per suggested fix of the violations in
+// the requested range
+func (s *Server) codeActions(params codeActionParams) []codeAction {
+	actions := []codeAction{}
+	filename, ok := fileOf(params.TextDocument.URI)
+	if !ok || s.published[filename] == nil {
+		return actions
+	}
+
+	p := s.published[filename]
+	for _, it := range p.items {
+		if !it.diagnostic.Range.overlaps(params.Range) {
+			continue
+		}
+		for _, fix := range it.violation.SuggestedFixes {
+			edit, err := s.workspaceEdit(filename, p.doc, fix)
+			if err != nil {
+				s.log(messageWarning, err.Error())
+				continue
+			}
+			title := fix.Message
+			if title == "" {
+				title = "Fix " + it.violation.Rule
+			}
+			actions = append(actions, codeAction{
+				Title:       title,
+				Kind:        kindQuickFix,
+				Diagnostics: []diagnostic{it.diagnostic},
+				IsPreferred: len(it.violation.SuggestedFixes) == 1,
+				Edit:        edit,
+			})
+		}
+	}
+	return actions
+}
+
+// workspaceEdit converts the byte offset edits of a fix, in filename unless
+// they name another file, into LSP text edits
+func (s *Server) workspaceEdit(filename string, doc *document, fix rules.SuggestedFix) (*workspaceEdit, error) {
+	edit := &workspaceEdit{Changes: make(map[string][]textEdit)}
+	docs := map[string]*document{filename: doc}
+	for _, e := range fix.Edits {
+		file := e.File
+		if file == "" {
+			file = filename
+		}
+		d, ok := docs[file]
+		if !ok {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			d = newDocument(content)
+			docs[file] = d
+		}
+
+		uri := uriOf(file)
+		edit.Changes[uri] = append(edit.Changes[uri], textEdit{
+			Range:   span{Start: d.position(e.Start), End: d.position(e.End)},
+			NewText: e.NewText,
+		})
+	}
+	return edit, nil
+}
+
+// hover returns the documentation of the rules of the diagnostics at the
+// requested position, or nil if there are none
+func (s *Server) hover(params hoverParams) *hover {
+	filename, ok := fileOf(params.TextDocument.URI)
+	if !ok || s.published[filename] == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	var hovered *span
+	explained := make(map[string]bool)
+	for _, it := range s.published[filename].items {
+		if !it.diagnostic.Range.contains(params.Position) || explained[it.violation.Rule] {
+			continue
+		}
+		rule := s.rule(it.violation.Rule, filename)
+		if rule == nil {
+			continue
+		}
+		explained[it.violation.Rule] = true
+		if hovered == nil {
+			r := it.diagnostic.Range
+			hovered = &r
+		} else {
+			_, _ = fmt.Fprintln(&buf)
+		}
+		rules.Explain(&buf, rule)
+	}
+	if hovered == nil {
+		return nil
+	}
+	return &hover{Contents: markupContent{Kind: "plaintext", Value: buf.String()}, Range: hovered}
+}
+
+// show displays a message to the user
+func (s *Server) show(messageType int, message string) {
+	_ = s.conn.notify("window/showMessage", showMessageParams{Type: messageType, Message: message})
+}
+
+// log writes a message to the client's log
+func (s *Server) log(messageType int, message string) {
+	_ = s.conn.notify("window/logMessage", showMessageParams{Type: messageType, Message: message})
+}
+
+// fileOf returns the path of a file: URI
+func fileOf(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///C:/dir has the path /C:/dir
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.Clean(filepath.FromSlash(path)), true
+}
+
+// goFile returns the path of a file: URI naming a Go file
+func goFile(uri string) (string, bool) {
+	filename, ok := fileOf(uri)
+	return filename, ok && strings.HasSuffix(filename, ".go")
+}
+
+// uriOf returns the file: URI of a path
+func uriOf(filename string) string {
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
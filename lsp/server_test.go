@@ -0,0 +1,205 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/analyzer"
+	"github.com/Arneball/goasted/rules"
+)
+
+// client drives a Server over pipes
+type client struct {
+	t    *testing.T
+	conn *conn
+	done chan error
+}
+
+// startServer serves a workspace analyzed with the default rules
+func startServer(t *testing.T) *client {
+	t.Helper()
+
+	server := NewServer(Config{Open: func(root string) (*Workspace, error) {
+		return &Workspace{Analyzer: analyzer.New(rules.DefaultRegistry())}, nil
+	}})
+	clientRead, serverWrite := io.Pipe()
+	serverRead, clientWrite := io.Pipe()
+
+	c := &client{t: t, conn: newConn(clientRead, clientWrite), done: make(chan error, 1)}
+	go func() {
+		err := server.Serve(context.Background(), serverRead, serverWrite)
+		_ = serverWrite.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() { _ = clientWrite.Close() })
+	return c
+}
+
+// request sends a request and decodes the result of its response into
+// result, skipping the notifications sent meanwhile
+func (c *client) request(id int, method string, params, result any) *rpcError {
+	c.t.Helper()
+	if err := c.conn.write(outgoing{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		c.t.Fatalf("Failed to send %s: %v", method, err)
+	}
+	for {
+		msg := c.next()
+		if msg.Method != "" || string(msg.ID) != strconv.Itoa(id) {
+			continue
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatalf("Failed to decode the result of %s: %v", method, err)
+		}
+		return nil
+	}
+}
+
+// notify sends a notification
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("Failed to send %s: %v", method, err)
+	}
+}
+
+// diagnostics waits for the next diagnostics published
+func (c *client) diagnostics() publishDiagnosticsParams {
+	c.t.Helper()
+	for {
+		msg := c.next()
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatalf("Failed to decode diagnostics: %v", err)
+		}
+		return params
+	}
+}
+
+// next reads the next message from the server
+func (c *client) next() *message {
+	c.t.Helper()
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatalf("Failed to read from the server: %v", err)
+	}
+	return msg
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "store.go")
+	files := map[string]string{
+		"go.mod": "module example.com/store\n\ngo 1.22\n",
+		"store.go": `package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+func Load(ctx context.Context, db *sql.DB) {
+	db.Query("SELECT 1")
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uri := uriOf(filename)
+
+	c := startServer(t)
+	var init initializeResult
+	if err := c.request(1, "initialize", initializeParams{RootURI: uriOf(dir)}, &init); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if !init.Capabilities.HoverProvider || !init.Capabilities.TextDocumentSync.OpenClose {
+		t.Errorf("Expected hover and open/close support, got %+v", init.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	c.notify("textDocument/didOpen", didOpenTextDocumentParams{TextDocument: textDocumentItem{URI: uri, LanguageID: "go", Version: 1}})
+	published := c.diagnostics()
+	if published.URI != uri || len(published.Diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic for %s, got %+v", uri, published)
+	}
+	d := published.Diagnostics[0]
+	wantRange := span{Start: position{8, 1}, End: position{8, 3}}
+	if d.Code != "sql-context-required" || d.Severity != severityError || d.Range != wantRange {
+		t.Errorf("Expected an sql-context-required error at %+v, got %+v", wantRange, d)
+	}
+
+	// The suggested fix is a quick fix
+	var actions []codeAction
+	if err := c.request(2, "textDocument/codeAction", codeActionParams{TextDocument: textDocumentIdentifier{URI: uri}, Range: d.Range}, &actions); err != nil {
+		t.Fatalf("codeAction failed: %v", err)
+	}
+	if len(actions) != 1 || actions[0].Kind != kindQuickFix || actions[0].Edit == nil {
+		t.Fatalf("Expected 1 quick fix, got %+v", actions)
+	}
+	edits := actions[0].Edit.Changes[uri]
+	if len(edits) == 0 || !strings.Contains(edits[0].NewText, "QueryContext") {
+		t.Errorf("Expected an edit to QueryContext, got %+v", edits)
+	}
+
+	// Hovering the diagnostic explains the rule
+	var h hover
+	if err := c.request(3, "textDocument/hover", hoverParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: position{8, 2}}, &h); err != nil {
+		t.Fatalf("hover failed: %v", err)
+	}
+	if !strings.HasPrefix(h.Contents.Value, "sql-context-required - ") || !strings.Contains(h.Contents.Value, "Good:") {
+		t.Errorf("Expected the explanation of sql-context-required, got %q", h.Contents.Value)
+	}
+	var none *hover
+	if err := c.request(4, "textDocument/hover", hoverParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: position{0, 0}}, &none); err != nil || none != nil {
+		t.Errorf("Expected no hover away from diagnostics, got %+v (%v)", none, err)
+	}
+
+	// Saving the fixed file clears the diagnostic
+	fixed := strings.Replace(files["store.go"], `db.Query("SELECT 1")`, `db.QueryContext(ctx, "SELECT 1")`, 1)
+	if err := os.WriteFile(filename, []byte(fixed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("textDocument/didSave", didSaveTextDocumentParams{TextDocument: textDocumentIdentifier{URI: uri}})
+	if published := c.diagnostics(); len(published.Diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics after the fix, got %+v", published.Diagnostics)
+	}
+
+	// Reopening the file analyzes what changed on disk while it was closed
+	c.notify("textDocument/didClose", didCloseTextDocumentParams{TextDocument: textDocumentIdentifier{URI: uri}})
+	c.diagnostics()
+	if err := os.WriteFile(filename, []byte(files["store.go"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("textDocument/didOpen", didOpenTextDocumentParams{TextDocument: textDocumentItem{URI: uri, LanguageID: "go", Version: 2}})
+	if published := c.diagnostics(); len(published.Diagnostics) != 1 {
+		t.Errorf("Expected 1 diagnostic after reopening the reverted file, got %+v", published.Diagnostics)
+	}
+
+	var unknown any
+	if err := c.request(5, "workspace/symbol", struct{}{}, &unknown); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("Expected method not found, got %v", err)
+	}
+
+	var result any
+	if err := c.request(6, "shutdown", nil, &result); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Expected a clean exit, got %v", err)
+	}
+}
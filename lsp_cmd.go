@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/Arneball/goasted/analyzer"
+	"github.com/Arneball/goasted/lsp"
+)
+
+// runLSP implements "goasted lsp": serve the Language Server Protocol on
+// stdin and stdout
+func runLSP(args []string) int {
+	var tags, rulesList string
+
+	fs := flag.NewFlagSet("goasted lsp", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: goasted lsp [flags]")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&tags, "tags", "", "Comma-separated list of build tags to load the code with")
+	fs.StringVar(&rulesList, "rules", "all", "Comma-separated list of rules to run (default: all)")
+	_ = fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := lsp.NewServer(lsp.Config{
+		Open: func(root string) (*lsp.Workspace, error) {
+			return openWorkspace(root, tags, rulesList)
+		},
+	})
+	if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// openWorkspace sets up the analysis of the workspace rooted at root, with
+// its plugins and configuration
+func openWorkspace(root, tags, rulesList string) (*lsp.Workspace, error) {
+	a, err := newAnalyzer([]string{root}, rulesList)
+	if err != nil {
+		return nil, err
+	}
+	builds, err := analyzer.BuildMatrix(tags, "")
+	if err != nil {
+		closeAnalyzer(a)
+		return nil, err
+	}
+
+	a.SetBuildConfigs(builds)
+	return &lsp.Workspace{
+		Analyzer: a,
+		Close:    func() { closeAnalyzer(a) },
+	}, nil
+}
@@ -1,50 +1,281 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Arneball/goasted/analyzer"
+	"github.com/Arneball/goasted/changes"
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/fixer"
 	"github.com/Arneball/goasted/formatter"
 	"github.com/Arneball/goasted/rules"
 )
 
 func main() {
-	var path string
-	var rulesList string
-	var outputFormat string
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "baseline":
+			os.Exit(runBaseline(os.Args[2:]))
+		case "rules":
+			os.Exit(runRules(os.Args[2:]))
+		case "explain":
+			os.Exit(runExplain(os.Args[2:]))
+		case "cache":
+			os.Exit(runCache(os.Args[2:]))
+		case "watch":
+			os.Exit(runWatch(os.Args[2:]))
+		case "lsp":
+			os.Exit(runLSP(os.Args[2:]))
+		}
+	}
+	os.Exit(runLint(os.Args[1:]))
+}
+
+// analysisFlags are the flags shared by every command that runs an analysis
+type analysisFlags struct {
+	path                string
+	rulesList           string
+	reportUnusedIgnores bool
+	tags                string
+	platforms           string
+	jobs                int
+	timeout             time.Duration
+	ruleTimeout         time.Duration
+	stats               bool
+	cpuProfile          string
+	trace               string
+	noCache             bool
+
+	// patterns are the positional package patterns. They take precedence
+	// over -path.
+	patterns []string
+}
+
+// register adds the analysis flags to fs
+func (af *analysisFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&af.path, "path", ".", "Path to analyze (file or directory)")
+	fs.StringVar(&af.rulesList, "rules", "all", "Comma-separated list of rules to run (default: all)")
+	fs.BoolVar(&af.reportUnusedIgnores, "report-unused-ignores", false, "Report //goasted:ignore directives that no longer match any violation")
+	fs.StringVar(&af.tags, "tags", "", "Comma-separated list of build tags to load the code with")
+	fs.IntVar(&af.jobs, "j", runtime.GOMAXPROCS(0), "Number of files or package rules to analyze in parallel")
+	fs.DurationVar(&af.timeout, "timeout", 0, "Abort the analysis after this long, e.g. 5m (default: no timeout)")
+	fs.DurationVar(&af.ruleTimeout, "rule-timeout", 0, "Report a rule as an internal error when it runs longer than this on a file or package (default: no budget)")
+	fs.StringVar(&af.platforms, "platforms", "", "Comma-separated list of os/arch platforms to analyze, e.g. linux/amd64,windows/arm64")
+	fs.BoolVar(&af.stats, "stats", false, "Print the time spent loading, type-checking and in each rule to stderr")
+	fs.StringVar(&af.cpuProfile, "cpuprofile", "", "Write a CPU profile of the analysis to this file")
+	fs.StringVar(&af.trace, "trace", "", "Write an execution trace of the analysis to this file")
+	fs.BoolVar(&af.noCache, "no-cache", false, "Analyze every package, without reading or writing the result cache")
+}
+
+// analyze loads the configuration, runs the selected rules and returns the
+// violations together with the registry of rules that ran
+func (af *analysisFlags) analyze() ([]rules.Violation, *rules.Registry, error) {
+	a, err := newAnalyzer(af.configDirs(), af.rulesList)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeAnalyzer(a)
+
+	builds, err := analyzer.BuildMatrix(af.tags, af.platforms)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	flag.StringVar(&path, "path", ".", "Path to analyze (file or directory)")
-	flag.StringVar(&rulesList, "rules", "all", "Comma-separated list of rules to run (default: all)")
-	flag.StringVar(&outputFormat, "format", "text", "Output format: text or junit (default: text)")
-	flag.Parse()
+	a.SetReportUnusedIgnores(af.reportUnusedIgnores)
+	a.SetBuildConfigs(builds)
+	a.SetConcurrency(af.jobs)
+	a.SetRuleTimeout(af.ruleTimeout)
+	if !af.noCache {
+		c, err := openCache("")
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: not caching results: %v\n", err)
+		}
+		a.SetCache(c)
+	}
+
+	ctx := context.Background()
+	if af.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, af.timeout)
+		defer cancel()
+	}
+
+	stopProfiling, err := startProfiling(af.cpuProfile, af.trace)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stopProfiling()
+
+	// Run analysis
+	start := time.Now()
+	dir, patterns, err := af.targets()
+	if err != nil {
+		return nil, nil, fmt.Errorf("analyzing code: %w", err)
+	}
+	result, err := a.Run(ctx, dir, patterns)
+	if err != nil {
+		return nil, nil, fmt.Errorf("analyzing code: %w", err)
+	}
+	if af.stats {
+		printStats(os.Stderr, result, time.Since(start))
+	}
 
-	// Initialize rule registry
+	// Describe the rules that may have run, for reports
 	registry := rules.NewRegistry()
-	registry.Register(rules.TestifyRule{})
-	registry.Register(rules.SqlContextRule{})
-	registry.Register(rules.GokitRule{})
+	for _, dir := range af.configDirs() {
+		dirRules, err := a.RegistryFor(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, rule := range dirRules.GetCheckers() {
+			if registry.GetChecker(rule.Name()) == nil {
+				registry.Register(rule)
+			}
+		}
+	}
+	return result.Violations, registry, nil
+}
+
+// targets returns the directory and package patterns to analyze, from the
+// positional patterns or -path
+func (af *analysisFlags) targets() (string, []string, error) {
+	if len(af.patterns) > 0 {
+		return ".", af.patterns, nil
+	}
+
+	return analyzer.Target(af.path)
+}
 
-	// Filter rules if specific rules are requested
+// newAnalyzer returns an analyzer of the built-in rules and the plugins
+// and pattern rules declared in the configuration of each analyzed file,
+// restricted to a comma-separated list of rule names unless the list is
+// "all" or empty. The configuration of dirs is loaded up front to report
+// mistakes before analyzing.
+func newAnalyzer(dirs []string, rulesList string) (*analyzer.Analyzer, error) {
+	registry := rules.DefaultRegistry()
+	a := analyzer.New(registry)
+	a.SetConfig(config.NewResolver(registry))
 	if rulesList != "all" && rulesList != "" {
 		ruleNames := strings.Split(rulesList, ",")
 		// Trim whitespace from each rule name
 		for i, name := range ruleNames {
 			ruleNames[i] = strings.TrimSpace(name)
 		}
-		registry = registry.Filter(ruleNames)
+		a.SetRules(ruleNames)
 	}
 
-	// Create analyzer
-	a := analyzer.New(registry)
+	// Load project configuration, validating rule names against all known
+	// rules and compiling the declared patterns
+	for _, dir := range dirs {
+		if _, err := a.RegistryFor(dir); err != nil {
+			return nil, fmt.Errorf("loading configuration: %w", err)
+		}
+	}
+	return a, nil
+}
 
-	// Run analysis
-	violations, err := a.Analyze(path)
+// closeAnalyzer stops the plugins started by a, reporting failures on stderr
+func closeAnalyzer(a *analyzer.Analyzer) {
+	if err := a.Close(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// configDirs returns the directories where configuration discovery starts
+// for the analyzed paths and patterns
+func (af *analysisFlags) configDirs() []string {
+	if len(af.patterns) == 0 {
+		return []string{configDir(af.path)}
+	}
+
+	dirs := []string{"."}
+	for _, pattern := range af.patterns {
+		dir := strings.TrimSuffix(filepath.ToSlash(pattern), "/...")
+		if _, err := os.Stat(dir); err == nil {
+			dirs = append(dirs, configDir(filepath.FromSlash(dir)))
+		}
+	}
+	return dirs
+}
+
+// root returns the directory the analysis is relative to
+func (af *analysisFlags) root() string {
+	if len(af.patterns) > 0 {
+		return "."
+	}
+	return configDir(af.path)
+}
+
+// runLint runs the default command: analyze, report and exit
+func runLint(args []string) int {
+	var af analysisFlags
+	var outputFormat string
+	var fix bool
+	var showDiff bool
+	var failOn string
+	var baselineFile string
+	var newFromRev string
+	var newFromPatch string
+
+	fs := flag.NewFlagSet("goasted", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: goasted [flags] [packages]")
+		fs.PrintDefaults()
+	}
+	af.register(fs)
+	fs.StringVar(&outputFormat, "format", "text", "Output format: text, junit or sarif (default: text)")
+	fs.StringVar(&failOn, "fail-on", "error", "Lowest severity that makes the run fail: error, warning, info or never")
+	fs.BoolVar(&fix, "fix", false, "Apply suggested fixes to the analyzed files")
+	fs.BoolVar(&showDiff, "diff", false, "Print suggested fixes as a unified diff instead of applying them")
+	fs.StringVar(&baselineFile, "baseline", "", "Only report violations that are not in this baseline file")
+	fs.StringVar(&newFromRev, "new-from-rev", "", "Only report violations on lines changed since this git revision")
+	fs.StringVar(&newFromPatch, "new-from-patch", "", "Only report violations on lines added by this unified diff")
+	_ = fs.Parse(args)
+	af.patterns = fs.Args()
+
+	threshold, err := parseFailOn(failOn)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	violations, registry, err := af.analyze()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+
+	// Hide violations recorded in the baseline and restrict to changed lines
+	violations, err = filterReported(violations, baselineFile, af.root(), newFromRev, newFromPatch, os.Stderr)
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Error analyzing code: %v\n", err)
-		os.Exit(1)
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+
+	// Apply or preview suggested fixes
+	if fix || showDiff {
+		violations, err = applyFixes(violations, showDiff, os.Stdout)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error applying fixes: %v\n", err)
+			return 1
+		}
+	}
+
+	// With -diff the diff replaces the report
+	if showDiff {
+		if shouldFail(violations, threshold) {
+			return 1
+		}
+		return 0
 	}
 
 	// Select formatter
@@ -52,22 +283,151 @@ func main() {
 	switch outputFormat {
 	case "junit":
 		f = formatter.JUnitFormatter{}
+	case "sarif":
+		// Locate files relative to the working directory, usually the
+		// repository root in CI
+		wd, _ := os.Getwd()
+		f = formatter.SARIFFormatter{Registry: registry, BaseDir: wd}
 	case "text":
 		f = formatter.TextFormatter{}
 	default:
-		_, _ = fmt.Fprintf(os.Stderr, "Unknown output format: %s (valid options: text, junit)\n", outputFormat)
-		os.Exit(1)
+		_, _ = fmt.Fprintf(os.Stderr, "Unknown output format: %s (valid options: text, junit, sarif)\n", outputFormat)
+		return 1
 	}
 
 	// Format and output violations
 	if err := f.Format(violations, os.Stdout); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	// Exit with appropriate code
-	if len(violations) > 0 {
-		os.Exit(1)
+	if shouldFail(violations, threshold) {
+		return 1
+	}
+	return 0
+}
+
+// filterReported hides the violations recorded in the baseline file, if
+// any, then keeps those on changed lines if rev or patch is set. The
+// baseline sees every violation, so that entries on unchanged lines aren't
+// reported to w as stale.
+func filterReported(violations []rules.Violation, baselineFile, dir, rev, patch string, w io.Writer) ([]rules.Violation, error) {
+	var err error
+	if baselineFile != "" {
+		violations, err = filterBaseline(violations, baselineFile, w)
+		if err != nil {
+			return nil, fmt.Errorf("applying baseline: %w", err)
+		}
+	}
+	if rev != "" || patch != "" {
+		violations, err = filterChanged(violations, dir, rev, patch)
+		if err != nil {
+			return nil, fmt.Errorf("computing changed lines: %w", err)
+		}
+	}
+	return violations, nil
+}
+
+// filterChanged keeps only the violations on lines added or modified since
+// rev in the repository containing dir, or by the patch file
+func filterChanged(violations []rules.Violation, dir, rev, patch string) ([]rules.Violation, error) {
+	var set *changes.Set
+	var err error
+	if patch != "" {
+		cwd, cwdErr := os.Getwd()
+		if cwdErr != nil {
+			return nil, cwdErr
+		}
+		set, err = changes.FromPatch(patch, cwd)
+	} else {
+		set, err = changes.FromRev(dir, rev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return set.Filter(violations), nil
+}
+
+// parseFailOn parses the -fail-on flag into the lowest failing severity.
+// "never" is returned as rules.SeverityUnset.
+func parseFailOn(failOn string) (rules.Severity, error) {
+	if failOn == "never" {
+		return rules.SeverityUnset, nil
 	}
-	os.Exit(0)
+	threshold, err := rules.ParseSeverity(failOn)
+	if err != nil {
+		return rules.SeverityUnset, fmt.Errorf("invalid -fail-on value %q (valid options: error, warning, info, never)", failOn)
+	}
+	return threshold, nil
+}
+
+// shouldFail reports whether any violation is at or above threshold
+func shouldFail(violations []rules.Violation, threshold rules.Severity) bool {
+	if threshold == rules.SeverityUnset {
+		return false
+	}
+	for _, v := range violations {
+		if v.Severity >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// configDir returns the directory where configuration discovery starts for
+// the analyzed path
+func configDir(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// applyFixes applies the suggested fixes of all violations, or prints them as
+// a unified diff to w when showDiff is set. It returns the violations that are
+// still outstanding after fixing.
+func applyFixes(violations []rules.Violation, showDiff bool, w io.Writer) ([]rules.Violation, error) {
+	result, err := fixer.Apply(violations)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range result.Skipped {
+		_, _ = fmt.Fprintf(os.Stderr, "%s:%d:%d: [%s] fix skipped: conflicts with another fix or does not produce valid Go\n", v.File, v.Line, v.Column, v.Rule)
+	}
+
+	if showDiff {
+		files := make([]string, 0, len(result.Files))
+		for file := range result.Files {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			change := result.Files[file]
+			_, _ = fmt.Fprint(w, fixer.UnifiedDiff(file, change.Original, change.Fixed))
+		}
+		return violations, nil
+	}
+
+	if err := result.Write(); err != nil {
+		return nil, err
+	}
+
+	fixed := make(map[string]bool)
+	for _, v := range result.Fixed {
+		fixed[violationKey(v)] = true
+	}
+	var remaining []rules.Violation
+	for _, v := range violations {
+		if !fixed[violationKey(v)] {
+			remaining = append(remaining, v)
+		}
+	}
+	return remaining, nil
+}
+
+// violationKey identifies a violation by position, rule and message
+func violationKey(v rules.Violation) string {
+	return fmt.Sprintf("%s:%d:%d:%s:%s", v.File, v.Line, v.Column, v.Rule, v.Message)
 }
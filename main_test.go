@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Arneball/goasted/baseline"
+	"github.com/Arneball/goasted/rules"
+)
+
+func TestFilterReported_BaselineBeforeChangedLines(t *testing.T) {
+	dir := t.TempDir()
+	src := "package p\n\nfunc a() { old() }\n\nfunc b() {\n\tbaselined()\n\tadded()\n}\n"
+	filename := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	violations := []rules.Violation{
+		{File: filename, Line: 3, Rule: "r", Message: "unchanged line"},
+		{File: filename, Line: 6, Rule: "r", Message: "changed line, in the baseline"},
+		{File: filename, Line: 7, Rule: "r", Message: "changed line, new"},
+	}
+
+	baselineFile := filepath.Join(dir, "baseline.json")
+	b, err := baseline.New(dir, violations[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write(baselineFile); err != nil {
+		t.Fatal(err)
+	}
+	patch := filepath.Join(dir, "change.patch")
+	diff := "--- a/p.go\n+++ b/p.go\n@@ -5,1 +6,2 @@\n+\tbaselined()\n+\tadded()\n"
+	if err := os.WriteFile(patch, []byte(diff), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	var stderr bytes.Buffer
+	got, err := filterReported(violations, baselineFile, dir, "", patch, &stderr)
+	if err != nil {
+		t.Fatalf("filterReported failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Line != 7 {
+		t.Errorf("Expected only the new violation on a changed line, got %+v", got)
+	}
+	// The entry on the unchanged line still matches a violation
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no stale baseline entries, got %q", stderr.String())
+	}
+}
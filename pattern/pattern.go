@@ -0,0 +1,493 @@
+// Package pattern matches Go code against structural patterns, in the style
+// of gogrep, and wraps patterns declared in the project configuration as
+// rules.
+//
+// A pattern is a Go expression in which metavariables stand for code:
+//
+//	$x      matches any single expression and binds it to x
+//	$*xs    matches any number of expressions in a list, such as call
+//	        arguments, and binds them to xs
+//	$_      matches any single expression without binding it
+//
+// A metavariable used twice must match the same code both times, so
+// "$x == $x" finds comparisons of an expression with itself. Everything
+// else must match literally, ignoring formatting and comments.
+//
+// Metavariables can be constrained to types, written as go/types prints
+// them, e.g. "*database/sql.DB". A constrained metavariable only matches
+// expressions of that type, which requires type information.
+package pattern
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Identifier prefixes metavariables are rewritten to before parsing
+const (
+	varPrefix  = "goasted_pattern_var_"
+	listPrefix = "goasted_pattern_list_"
+)
+
+// metavar matches a metavariable in a pattern or template
+var metavar = regexp.MustCompile(`\$(\*?)([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Pattern is a compiled pattern
+type Pattern struct {
+	root  ast.Expr
+	where map[string]string
+	vars  map[string]bool
+}
+
+// Compile parses src and attaches the type constraints in where, which maps
+// metavariables such as "$db" to type names
+func Compile(src string, where map[string]string) (*Pattern, error) {
+	root, vars, err := parseTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pattern{root: root, where: make(map[string]string), vars: vars}
+	for name, typ := range where {
+		name = strings.TrimPrefix(name, "$")
+		if _, ok := vars[name]; !ok || name == "_" {
+			return nil, fmt.Errorf("constraint on $%s, which the pattern doesn't bind", name)
+		}
+		p.where[name] = typ
+	}
+	return p, nil
+}
+
+// parseTemplate parses an expression with metavariables and returns it with
+// the names of its metavariables, mapped to whether they are lists
+func parseTemplate(src string) (ast.Expr, map[string]bool, error) {
+	vars := make(map[string]bool)
+	var conflict string
+	rewritten := metavar.ReplaceAllStringFunc(src, func(m string) string {
+		sub := metavar.FindStringSubmatch(m)
+		list, name := sub[1] == "*", sub[2]
+		if seen, ok := vars[name]; ok && seen != list && name != "_" {
+			conflict = name
+		}
+		vars[name] = list
+		if list {
+			return listPrefix + name
+		}
+		return varPrefix + name
+	})
+	if conflict != "" {
+		return nil, nil, fmt.Errorf("$%s is used both as $%s and $*%s", conflict, conflict, conflict)
+	}
+
+	root, err := parser.ParseExpr(rewritten)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid pattern %q: %w", src, err)
+	}
+	return root, vars, nil
+}
+
+// Match is the code bound to the metavariables by a successful match
+type Match struct {
+	// Node is the matched expression
+	Node ast.Expr
+
+	bindings map[string]binding
+}
+
+// binding is the code bound to one metavariable
+type binding struct {
+	node ast.Node
+	list []ast.Node
+
+	// ellipsis is set when a list of call arguments ends in "..."
+	ellipsis bool
+}
+
+// Match matches the pattern against node, using info to check type
+// constraints
+func (p *Pattern) Match(info *types.Info, node ast.Node) (*Match, bool) {
+	expr, ok := node.(ast.Expr)
+	if !ok {
+		return nil, false
+	}
+	m := &matcher{pattern: p, info: info, bindings: make(map[string]binding)}
+	if !m.match(p.root, expr) {
+		return nil, false
+	}
+	return &Match{Node: expr, bindings: m.bindings}, true
+}
+
+// FindAll returns the matches in root in source order, including matches
+// nested inside other matches
+func (p *Pattern) FindAll(info *types.Info, root ast.Node) []*Match {
+	var matches []*Match
+	ast.Inspect(root, func(n ast.Node) bool {
+		if m, ok := p.Match(info, n); ok {
+			matches = append(matches, m)
+		}
+		return true
+	})
+	return matches
+}
+
+// matcher holds the state of one match attempt
+type matcher struct {
+	pattern  *Pattern
+	info     *types.Info
+	bindings map[string]binding
+}
+
+// varName returns the metavariable an identifier stands for
+func varName(ident *ast.Ident) (name string, list, ok bool) {
+	if name, ok := strings.CutPrefix(ident.Name, listPrefix); ok {
+		return name, true, true
+	}
+	if name, ok := strings.CutPrefix(ident.Name, varPrefix); ok {
+		return name, false, true
+	}
+	return "", false, false
+}
+
+// match matches a pattern node against a code node
+func (m *matcher) match(pattern, node ast.Node) bool {
+	if isNil(pattern) || isNil(node) {
+		return isNil(pattern) && isNil(node)
+	}
+
+	if ident, ok := pattern.(*ast.Ident); ok {
+		if name, list, isVar := varName(ident); isVar {
+			if list {
+				// A list metavariable outside of a list matches one element
+				return m.bindList(name, []ast.Node{node})
+			}
+			return m.bind(name, node)
+		}
+	}
+
+	if call, ok := pattern.(*ast.CallExpr); ok {
+		return m.matchCall(call, node)
+	}
+
+	pv, nv := reflect.ValueOf(pattern), reflect.ValueOf(node)
+	if pv.Type() != nv.Type() {
+		return false
+	}
+	return m.matchFields(pv.Elem(), nv.Elem())
+}
+
+// matchCall matches a call, letting a trailing list metavariable match
+// arguments passed with "..."
+func (m *matcher) matchCall(pattern *ast.CallExpr, node ast.Node) bool {
+	call, ok := node.(*ast.CallExpr)
+	if !ok || !m.match(pattern.Fun, call.Fun) || !m.matchList(toNodes(pattern.Args), toNodes(call.Args)) {
+		return false
+	}
+	if pattern.Ellipsis.IsValid() || !call.Ellipsis.IsValid() {
+		return pattern.Ellipsis.IsValid() == call.Ellipsis.IsValid()
+	}
+
+	// f($*args) also matches f(a, b...), as long as $*args is last
+	if len(pattern.Args) == 0 {
+		return false
+	}
+	ident, ok := pattern.Args[len(pattern.Args)-1].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	name, list, isVar := varName(ident)
+	if !isVar || !list {
+		return false
+	}
+	if name != "_" {
+		b := m.bindings[name]
+		b.ellipsis = true
+		m.bindings[name] = b
+	}
+	return true
+}
+
+// matchFields matches two AST node structs field by field. Positions,
+// comments and resolved objects are ignored.
+func (m *matcher) matchFields(p, n reflect.Value) bool {
+	for i := 0; i < p.NumField(); i++ {
+		if ignoredField(p.Type().Field(i)) {
+			continue
+		}
+		if !m.matchValue(p.Field(i), n.Field(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchValue matches the values of a field
+func (m *matcher) matchValue(p, n reflect.Value) bool {
+	nodeType := reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+	switch {
+	case p.Type().Implements(nodeType):
+		pn, _ := p.Interface().(ast.Node)
+		nn, _ := n.Interface().(ast.Node)
+		return m.match(pn, nn)
+
+	case p.Kind() == reflect.Slice:
+		if ps, ok := sliceNodes(p); ok {
+			ns, _ := sliceNodes(n)
+			return m.matchList(ps, ns)
+		}
+		if p.Len() != n.Len() {
+			return false
+		}
+		for i := 0; i < p.Len(); i++ {
+			if !m.matchValue(p.Index(i), n.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return p.Interface() == n.Interface()
+	}
+}
+
+// ignoredField reports whether a field of an AST node is irrelevant to
+// matching
+func ignoredField(f reflect.StructField) bool {
+	switch f.Type {
+	case reflect.TypeOf(token.NoPos), reflect.TypeOf((*ast.Object)(nil)),
+		reflect.TypeOf((*ast.Scope)(nil)), reflect.TypeOf((*ast.CommentGroup)(nil)):
+		return true
+	}
+	return false
+}
+
+// matchList matches a list of pattern nodes, which may contain list
+// metavariables, against a list of code nodes
+func (m *matcher) matchList(patterns, nodes []ast.Node) bool {
+	if len(patterns) == 0 {
+		return len(nodes) == 0
+	}
+
+	if ident, ok := patterns[0].(*ast.Ident); ok {
+		if name, list, isVar := varName(ident); isVar && list {
+			// Try the shortest binding first and backtrack
+			for i := 0; i <= len(nodes); i++ {
+				saved := maps.Clone(m.bindings)
+				if m.bindList(name, nodes[:i]) && m.matchList(patterns[1:], nodes[i:]) {
+					return true
+				}
+				m.bindings = saved
+			}
+			return false
+		}
+	}
+
+	if len(nodes) == 0 {
+		return false
+	}
+	saved := maps.Clone(m.bindings)
+	if m.match(patterns[0], nodes[0]) && m.matchList(patterns[1:], nodes[1:]) {
+		return true
+	}
+	m.bindings = saved
+	return false
+}
+
+// bind binds a metavariable to node, or checks node against an earlier
+// binding
+func (m *matcher) bind(name string, node ast.Node) bool {
+	if !m.satisfies(name, node) {
+		return false
+	}
+	if name == "_" {
+		return true
+	}
+	if b, ok := m.bindings[name]; ok {
+		return equal(b.node, node)
+	}
+	m.bindings[name] = binding{node: node}
+	return true
+}
+
+// bindList binds a list metavariable to nodes, or checks nodes against an
+// earlier binding
+func (m *matcher) bindList(name string, nodes []ast.Node) bool {
+	for _, node := range nodes {
+		if !m.satisfies(name, node) {
+			return false
+		}
+	}
+	if name == "_" {
+		return true
+	}
+	if b, ok := m.bindings[name]; ok {
+		if len(b.list) != len(nodes) {
+			return false
+		}
+		for i := range nodes {
+			if !equal(b.list[i], nodes[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	m.bindings[name] = binding{list: nodes}
+	return true
+}
+
+// satisfies checks node against the type constraint of a metavariable
+func (m *matcher) satisfies(name string, node ast.Node) bool {
+	want, ok := m.pattern.where[name]
+	if !ok {
+		return true
+	}
+	expr, ok := node.(ast.Expr)
+	if !ok || m.info == nil {
+		return false
+	}
+	typ := m.info.TypeOf(expr)
+	return typ != nil && types.TypeString(typ, nil) == want
+}
+
+// equal reports whether two nodes are the same code
+func equal(a, b ast.Node) bool {
+	m := &matcher{pattern: &Pattern{}, bindings: make(map[string]binding)}
+	return m.match(a, b)
+}
+
+// isNil reports whether node is nil or a typed nil pointer
+func isNil(node ast.Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// sliceNodes returns the elements of a slice of nodes, such as []ast.Expr
+func sliceNodes(v reflect.Value) ([]ast.Node, bool) {
+	nodeType := reflect.TypeOf((*ast.Node)(nil)).Elem()
+	if !v.Type().Elem().Implements(nodeType) {
+		return nil, false
+	}
+	nodes := make([]ast.Node, v.Len())
+	for i := range nodes {
+		nodes[i], _ = v.Index(i).Interface().(ast.Node)
+	}
+	return nodes, true
+}
+
+// toNodes converts expressions to nodes
+func toNodes(exprs []ast.Expr) []ast.Node {
+	nodes := make([]ast.Node, len(exprs))
+	for i, e := range exprs {
+		nodes[i] = e
+	}
+	return nodes
+}
+
+// Template is an expression with metavariables that is expanded with the
+// code bound by a match
+type Template struct {
+	src string
+}
+
+// CompileTemplate checks that src is an expression using only metavariables
+// of p
+func (p *Pattern) CompileTemplate(src string) (*Template, error) {
+	_, vars, err := parseTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedNames(vars) {
+		bound, ok := p.vars[name]
+		if !ok || name == "_" {
+			return nil, fmt.Errorf("template %q uses $%s, which the pattern doesn't bind", src, name)
+		}
+		if bound != vars[name] {
+			return nil, fmt.Errorf("template %q uses $%s differently from the pattern", src, name)
+		}
+	}
+	return &Template{src: src}, nil
+}
+
+// Expand replaces the metavariables in the template with the code bound by
+// match, printed with fset
+func (t *Template) Expand(fset *token.FileSet, match *Match) string {
+	return match.Expand(fset, t.src)
+}
+
+// Expand replaces the metavariables bound by the match in text with the code
+// they matched. Other text, including unknown metavariables, is kept. The
+// comma next to a list metavariable that matched nothing is dropped.
+func (m *Match) Expand(fset *token.FileSet, text string) string {
+	var out strings.Builder
+	last := 0
+	for _, loc := range metavar.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		list := loc[3] > loc[2]
+		b, ok := m.bindings[text[loc[4]:loc[5]]]
+		if !ok || !list && b.node == nil {
+			continue
+		}
+
+		out.WriteString(text[last:start])
+		last = end
+		if !list {
+			out.WriteString(printNode(fset, b.node))
+			continue
+		}
+
+		if len(b.list) == 0 {
+			trimmed := strings.TrimRight(out.String(), " ")
+			if strings.HasSuffix(trimmed, ",") {
+				trimmed = strings.TrimSuffix(trimmed, ",")
+				out.Reset()
+				out.WriteString(trimmed)
+			} else if rest := strings.TrimLeft(text[last:], " "); strings.HasPrefix(rest, ",") {
+				last = len(text) - len(strings.TrimLeft(rest[1:], " "))
+			}
+			continue
+		}
+
+		parts := make([]string, len(b.list))
+		for i, node := range b.list {
+			parts[i] = printNode(fset, node)
+		}
+		if b.ellipsis {
+			parts[len(parts)-1] += "..."
+		}
+		out.WriteString(strings.Join(parts, ", "))
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// printNode formats node as source code
+func printNode(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, node); err != nil {
+		return fmt.Sprintf("%v", node)
+	}
+	return buf.String()
+}
+
+// sortedNames returns the keys of vars in sorted order
+func sortedNames(vars map[string]bool) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,180 @@
+package pattern
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+const matchSource = `package demo
+
+type DB struct{}
+
+func (*DB) Query(query string, args ...any) error { return nil }
+
+type Cache struct{}
+
+func (*Cache) Query(query string, args ...any) error { return nil }
+
+func use(db *DB, cache *Cache, x, y int, args []any) {
+	_ = db.Query("a")
+	_ = db.Query("b", 1, 2)
+	_ = db.Query("c", args...)
+	_ = cache.Query("d")
+	_ = x == x
+	_ = x == y
+	_ = (x + 1) == (x + 1)
+}
+`
+
+// parseSource parses and type-checks src as package example.com/demo
+func parseSource(t *testing.T, src string) (*token.FileSet, *ast.File, *types.Info) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "demo.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if _, err := (&types.Config{}).Check("example.com/demo", fset, []*ast.File{file}, info); err != nil {
+		t.Fatalf("Failed to type-check: %v", err)
+	}
+	return fset, file, info
+}
+
+// findAll returns the matched code of every match of src
+func findAll(t *testing.T, src string, where map[string]string) []string {
+	t.Helper()
+
+	p, err := Compile(src, where)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	fset, file, info := parseSource(t, matchSource)
+
+	var found []string
+	for _, m := range p.FindAll(info, file) {
+		found = append(found, printNode(fset, m.Node))
+	}
+	return found
+}
+
+func TestPattern_Matches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		where   map[string]string
+		want    string
+	}{
+		{`$x.Query($q)`, nil, `db.Query("a")|cache.Query("d")`},
+		{`$x.Query($*args)`, nil, `db.Query("a")|db.Query("b", 1, 2)|db.Query("c", args...)|cache.Query("d")`},
+		{`$x.Query($q, $_, $*rest)`, nil, `db.Query("b", 1, 2)|db.Query("c", args...)`},
+		{`$db.Query($*args)`, map[string]string{"$db": "*example.com/demo.DB"}, `db.Query("a")|db.Query("b", 1, 2)|db.Query("c", args...)`},
+		{`$x == $x`, nil, `x == x|(x + 1) == (x + 1)`},
+		{`$x.Query("b", 1, 2)`, nil, `db.Query("b", 1, 2)`},
+		{`$_ == y`, nil, `x == y`},
+		{`$x.$m("d")`, nil, `cache.Query("d")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := strings.Join(findAll(t, tt.pattern, tt.where), "|"); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPattern_ConstraintsNeedTypes(t *testing.T) {
+	p, err := Compile(`$db.Query($*args)`, map[string]string{"$db": "*example.com/demo.DB"})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	_, file, _ := parseSource(t, matchSource)
+
+	if got := len(p.FindAll(nil, file)); got != 0 {
+		t.Errorf("Expected no matches without type information, got %d", got)
+	}
+}
+
+func TestMatch_Expand(t *testing.T) {
+	p, err := Compile(`$db.Query($q, $*args)`, nil)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	tmpl, err := p.CompileTemplate(`$db.QueryContext(ctx, $q, $*args)`)
+	if err != nil {
+		t.Fatalf("CompileTemplate failed: %v", err)
+	}
+	fset, file, info := parseSource(t, matchSource)
+
+	var got []string
+	for _, m := range p.FindAll(info, file) {
+		got = append(got, tmpl.Expand(fset, m))
+	}
+	want := `db.QueryContext(ctx, "a")|db.QueryContext(ctx, "b", 1, 2)|db.QueryContext(ctx, "c", args...)|cache.QueryContext(ctx, "d")`
+	if strings.Join(got, "|") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, "|"))
+	}
+
+	m, ok := p.Match(info, firstCall(t, file))
+	if !ok {
+		t.Fatalf("Expected the first call to match")
+	}
+	if got := m.Expand(fset, "wrap($*args, $q)"); got != `wrap("a")` {
+		t.Errorf("Expected the comma after an empty list dropped, got %s", got)
+	}
+}
+
+// firstCall returns the first call expression in file
+func firstCall(t *testing.T, file *ast.File) *ast.CallExpr {
+	t.Helper()
+
+	var call *ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok && call == nil {
+			call = c
+		}
+		return call == nil
+	})
+	if call == nil {
+		t.Fatalf("No call in file")
+	}
+	return call
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		where   map[string]string
+		want    string
+	}{
+		{`$x.Query(`, nil, "invalid pattern"},
+		{`$x.Query($*x)`, nil, "used both as $x and $*x"},
+		{`$x.Query()`, map[string]string{"$y": "int"}, "constraint on $y"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Compile(tt.pattern, tt.where); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.pattern, tt.want, err)
+		}
+	}
+
+	p, err := Compile(`$x.Query($*args)`, nil)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if _, err := p.CompileTemplate(`$y.Query()`); err == nil || !strings.Contains(err.Error(), "doesn't bind") {
+		t.Errorf("Expected unbound metavariable error, got %v", err)
+	}
+	if _, err := p.CompileTemplate(`$x.Query($args)`); err == nil || !strings.Contains(err.Error(), "differently") {
+		t.Errorf("Expected list mismatch error, got %v", err)
+	}
+}
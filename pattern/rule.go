@@ -0,0 +1,100 @@
+package pattern
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// Rule reports code matching a pattern declared in the configuration
+type Rule struct {
+	name    string
+	config  config.PatternConfig
+	pattern *Pattern
+	rewrite *Template
+}
+
+// New compiles the pattern rule declared as name
+func New(name string, cfg config.PatternConfig) (*Rule, error) {
+	p, err := Compile(cfg.Pattern, cfg.Where)
+	if err != nil {
+		return nil, fmt.Errorf("pattern rule %q: %w", name, err)
+	}
+
+	rule := &Rule{name: name, config: cfg, pattern: p}
+	if cfg.Rewrite != "" {
+		if rule.rewrite, err = p.CompileTemplate(cfg.Rewrite); err != nil {
+			return nil, fmt.Errorf("pattern rule %q: %w", name, err)
+		}
+	}
+	return rule, nil
+}
+
+// Name returns the rule's name
+func (r *Rule) Name() string {
+	return r.name
+}
+
+// Description returns the configured description, or the pattern
+func (r *Rule) Description() string {
+	if r.config.Description != "" {
+		return r.config.Description
+	}
+	return "Matches " + r.config.Pattern
+}
+
+// DefaultSeverity returns the configured severity, or error
+func (r *Rule) DefaultSeverity() rules.Severity {
+	if severity, err := rules.ParseSeverity(r.config.Severity); err == nil {
+		return severity
+	}
+	return rules.SeverityError
+}
+
+// Pattern returns the rule's declaration
+func (r *Rule) Pattern() config.PatternConfig {
+	return r.config
+}
+
+// Fingerprint identifies the rule by its declaration, so that cached
+// results are discarded when it changes
+func (r *Rule) Fingerprint() string {
+	data, err := json.Marshal(r.config)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Check reports every match of the pattern in the file
+func (r *Rule) Check(ctx *rules.Context) []rules.Violation {
+	var violations []rules.Violation
+	for _, match := range r.pattern.FindAll(ctx.TypeInfo, ctx.File) {
+		pos := ctx.FileSet.Position(match.Node.Pos())
+		v := rules.Violation{
+			File:    ctx.Filename,
+			Line:    pos.Line,
+			Column:  pos.Column,
+			Rule:    r.name,
+			Message: match.Expand(ctx.FileSet, r.config.Message),
+		}
+
+		if r.rewrite != nil {
+			replacement := r.rewrite.Expand(ctx.FileSet, match)
+			v.SuggestedFixes = []rules.SuggestedFix{{
+				Message: "Rewrite to " + replacement,
+				Edits: []rules.TextEdit{{
+					File:    ctx.Filename,
+					Start:   pos.Offset,
+					End:     ctx.FileSet.Position(match.Node.End()).Offset,
+					NewText: replacement,
+				}},
+			}}
+		}
+
+		violations = append(violations, v)
+	}
+	return violations
+}
@@ -0,0 +1,87 @@
+package pattern
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+const sqlSource = `package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+type cache struct{}
+
+func (cache) Query(key string) {}
+
+func load(ctx context.Context, db *sql.DB, c cache) {
+	rows, _ := db.Query("SELECT 1", 1)
+	_ = rows
+	c.Query("key")
+}
+`
+
+func TestRule_ReportsMatchesWithRewrite(t *testing.T) {
+	rule, err := New("query-context", config.PatternConfig{
+		Pattern:  "$db.Query($*args)",
+		Where:    map[string]string{"$db": "*database/sql.DB"},
+		Message:  "use $db.QueryContext",
+		Severity: "warning",
+		Rewrite:  "$db.QueryContext(ctx, $*args)",
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "repo.go", sqlSource, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("example.com/repo", fset, []*ast.File{file}, info); err != nil {
+		t.Fatalf("Failed to type-check: %v", err)
+	}
+
+	violations := rule.Check(&rules.Context{FileSet: fset, File: file, Filename: "repo.go", TypeInfo: info})
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, got %d: %v", len(violations), violations)
+	}
+	v := violations[0]
+	if v.Line != 13 || v.Rule != "query-context" || v.Message != "use db.QueryContext" {
+		t.Errorf("Unexpected violation: %+v", v)
+	}
+	if rule.DefaultSeverity() != rules.SeverityWarning {
+		t.Errorf("Expected configured severity warning, got %s", rule.DefaultSeverity())
+	}
+
+	if len(v.SuggestedFixes) != 1 || len(v.SuggestedFixes[0].Edits) != 1 {
+		t.Fatalf("Expected one fix with one edit, got %+v", v.SuggestedFixes)
+	}
+	edit := v.SuggestedFixes[0].Edits[0]
+	fixed := sqlSource[:edit.Start] + edit.NewText + sqlSource[edit.End:]
+	if !strings.Contains(fixed, `rows, _ := db.QueryContext(ctx, "SELECT 1", 1)`) {
+		t.Errorf("Unexpected fixed source:\n%s", fixed)
+	}
+}
+
+func TestNew_InvalidRewrite(t *testing.T) {
+	_, err := New("bad", config.PatternConfig{Pattern: "$x.Query()", Message: "m", Rewrite: "$y.QueryContext()"})
+	if err == nil || !strings.Contains(err.Error(), `pattern rule "bad"`) {
+		t.Errorf("Expected a rewrite error naming the rule, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package plugin runs external rule executables declared in the project
+// configuration, so teams can ship private rules without recompiling
+// goasted.
+//
+// goasted starts the plugin's command once and keeps it running. For every
+// file it writes a Request as one line of JSON to the plugin's stdin and
+// reads a Response as one line of JSON from its stdout. Anything the plugin
+// writes to stderr is passed through. The plugin should exit when its stdin
+// is closed.
+//
+// Both messages carry the protocol version. A plugin must answer with the
+// version it was asked for, or with an error if it doesn't support it.
+package plugin
+
+// ProtocolVersion is the version of the plugin protocol spoken by goasted
+const ProtocolVersion = 1
+
+// Request asks a plugin to check one file
+type Request struct {
+	Version int `json:"version"`
+
+	// File is the absolute path of the file to check
+	File string `json:"file"`
+
+	// Package describes the package the file belongs to
+	Package Package `json:"package"`
+
+	// Source is the file's content, sent if the plugin asks for "source"
+	Source string `json:"source,omitempty"`
+
+	// Uses lists every identifier use in the file that resolves to an
+	// object, sent if the plugin asks for "uses"
+	Uses []Use `json:"uses,omitempty"`
+}
+
+// Package is the metadata of a package
+type Package struct {
+	Path  string   `json:"path"`
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+}
+
+// Use is an identifier resolved by the type checker
+type Use struct {
+	Name   string `json:"name"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+
+	// Object is the qualified name of what the identifier refers to, e.g.
+	// "(*database/sql.DB).Query" or "fmt.Println"
+	Object string `json:"object"`
+
+	// Type is the object's type, e.g. "func(query string, args ...any) (*database/sql.Rows, error)"
+	Type string `json:"type"`
+}
+
+// Response lists the violations a plugin found in a file
+type Response struct {
+	Version    int         `json:"version"`
+	Violations []Violation `json:"violations"`
+
+	// Error reports that the plugin failed to check the file
+	Error string `json:"error,omitempty"`
+}
+
+// Violation is a violation reported by a plugin
+type Violation struct {
+	// File defaults to the requested file
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+
+	// Severity is error, warning or info. It defaults to the plugin's
+	// configured severity.
+	Severity string `json:"severity,omitempty"`
+}
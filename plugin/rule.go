@@ -0,0 +1,315 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// Rule wraps a plugin as a rules.Rule. The plugin process is started on the
+// first check and shared by all checks; requests are sent one at a time.
+type Rule struct {
+	name    string
+	config  config.PluginConfig
+	timeout time.Duration
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+	stopped error
+}
+
+// New creates a Rule running the plugin declared as name
+func New(name string, cfg config.PluginConfig) *Rule {
+	return &Rule{name: name, config: cfg}
+}
+
+// SetTimeout bounds the time the plugin may take to answer one request.
+// A plugin that doesn't answer in time is killed, and later checks report
+// it as failed. Zero means no limit.
+func (r *Rule) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.timeout = timeout
+}
+
+// Name returns the plugin's name
+func (r *Rule) Name() string {
+	return r.name
+}
+
+// Description returns the configured description
+func (r *Rule) Description() string {
+	if r.config.Description != "" {
+		return r.config.Description
+	}
+	return "External rule " + strings.Join(r.config.Command, " ")
+}
+
+// DefaultSeverity returns the configured severity, or error
+func (r *Rule) DefaultSeverity() rules.Severity {
+	if severity, err := rules.ParseSeverity(r.config.Severity); err == nil {
+		return severity
+	}
+	return rules.SeverityError
+}
+
+// Plugin returns the plugin's declaration
+func (r *Rule) Plugin() config.PluginConfig {
+	return r.config
+}
+
+// Fingerprint returns an empty fingerprint: a plugin's results may depend
+// on anything, so they are never cached
+func (r *Rule) Fingerprint() string {
+	return ""
+}
+
+// Check sends the file to the plugin and converts its response. Failures
+// of the plugin are reported as a violation of the rule on the file.
+func (r *Rule) Check(ctx *rules.Context) []rules.Violation {
+	req, err := r.request(ctx)
+	if err == nil {
+		var resp *Response
+		if resp, err = r.roundTrip(req); err == nil {
+			return r.violations(ctx, resp)
+		}
+	}
+
+	return []rules.Violation{{
+		File:     ctx.Filename,
+		Rule:     r.name,
+		Message:  fmt.Sprintf("plugin %s failed: %v", r.name, err),
+		Severity: rules.SeverityError,
+	}}
+}
+
+// request builds the request for a file
+func (r *Rule) request(ctx *rules.Context) (*Request, error) {
+	req := &Request{
+		Version: ProtocolVersion,
+		File:    ctx.Filename,
+		Package: Package{
+			Name:  ctx.File.Name.Name,
+			Files: ctx.PackageFiles,
+		},
+	}
+	if ctx.Package != nil {
+		req.Package.Path = ctx.Package.Path()
+	}
+	if req.Package.Files == nil {
+		req.Package.Files = []string{ctx.Filename}
+	}
+
+	if slices.Contains(r.config.Send, config.SendSource) {
+		source, err := os.ReadFile(ctx.Filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", ctx.Filename, err)
+		}
+		req.Source = string(source)
+	}
+	if slices.Contains(r.config.Send, config.SendUses) {
+		req.Uses = uses(ctx)
+	}
+
+	return req, nil
+}
+
+// uses lists the identifier uses in the file, in source order
+func uses(ctx *rules.Context) []Use {
+	if ctx.TypeInfo == nil {
+		return nil
+	}
+
+	var result []Use
+	ast.Inspect(ctx.File, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := ctx.TypeInfo.Uses[ident]
+		if obj == nil {
+			return true
+		}
+		pos := ctx.FileSet.Position(ident.Pos())
+		result = append(result, Use{
+			Name:   ident.Name,
+			Line:   pos.Line,
+			Column: pos.Column,
+			Object: objectName(obj),
+			Type:   types.TypeString(obj.Type(), nil),
+		})
+		return true
+	})
+	return result
+}
+
+// objectName returns the qualified name of an object
+func objectName(obj types.Object) string {
+	if fn, ok := obj.(*types.Func); ok {
+		return fn.FullName()
+	}
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// violations converts a plugin response into violations of the rule
+func (r *Rule) violations(ctx *rules.Context, resp *Response) []rules.Violation {
+	if resp.Error != "" {
+		return []rules.Violation{{
+			File:     ctx.Filename,
+			Rule:     r.name,
+			Message:  fmt.Sprintf("plugin %s failed: %s", r.name, resp.Error),
+			Severity: rules.SeverityError,
+		}}
+	}
+
+	violations := make([]rules.Violation, 0, len(resp.Violations))
+	for _, pv := range resp.Violations {
+		v := rules.Violation{
+			File:    pv.File,
+			Line:    pv.Line,
+			Column:  pv.Column,
+			Rule:    r.name,
+			Message: pv.Message,
+		}
+		if v.File == "" {
+			v.File = ctx.Filename
+		}
+		if severity, err := rules.ParseSeverity(pv.Severity); err == nil {
+			v.Severity = severity
+		}
+		violations = append(violations, v)
+	}
+	return violations
+}
+
+// roundTrip sends a request and waits for the response, starting the
+// plugin if needed
+func (r *Rule) roundTrip(req *Request) (*Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	// Killing a plugin that's out of time unblocks the exchange below
+	var deadline *time.Timer
+	if r.timeout > 0 {
+		process := r.cmd.Process
+		deadline = time.AfterFunc(r.timeout, func() { _ = process.Kill() })
+	}
+	line, err := r.exchange(data)
+	if deadline != nil && !deadline.Stop() {
+		return nil, r.stop(fmt.Errorf("no response within %s", r.timeout))
+	}
+	if err != nil {
+		return nil, r.stop(err)
+	}
+
+	resp := &Response{}
+	if err := json.Unmarshal(line, resp); err != nil {
+		return nil, r.stop(fmt.Errorf("invalid response: %w", err))
+	}
+	if resp.Version != ProtocolVersion {
+		return nil, r.stop(fmt.Errorf("plugin speaks protocol version %d, expected %d", resp.Version, ProtocolVersion))
+	}
+	return resp, nil
+}
+
+// exchange sends an encoded request and reads the response line
+func (r *Rule) exchange(data []byte) ([]byte, error) {
+	if _, err := r.stdin.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	line, err := r.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return line, nil
+}
+
+// start starts the plugin process unless it's running. A plugin that
+// stopped isn't restarted.
+func (r *Rule) start() error {
+	if r.stopped != nil {
+		return r.stopped
+	}
+	if r.cmd != nil {
+		return nil
+	}
+
+	cmd := exec.Command(r.config.Command[0], r.config.Command[1:]...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		r.stopped = fmt.Errorf("failed to start plugin: %w", err)
+		return r.stopped
+	}
+
+	r.cmd = cmd
+	r.stdin = stdin
+	r.stdout = bufio.NewReader(stdout)
+	return nil
+}
+
+// stop kills the plugin after a protocol error, which is then returned for
+// every later check
+func (r *Rule) stop(err error) error {
+	if r.cmd != nil {
+		_ = r.stdin.Close()
+		_ = r.cmd.Process.Kill()
+		_ = r.cmd.Wait()
+		r.cmd = nil
+	}
+	r.stopped = err
+	return err
+}
+
+// Close closes the plugin's stdin and waits for it to exit
+func (r *Rule) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cmd == nil {
+		return nil
+	}
+	_ = r.stdin.Close()
+	err := r.cmd.Wait()
+	r.cmd = nil
+	r.stopped = fmt.Errorf("plugin %s is closed", r.name)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", r.name, err)
+	}
+	return nil
+}
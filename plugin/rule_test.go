@@ -0,0 +1,194 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// helperEnv makes the test binary act as a plugin in the mode it names
+const helperEnv = "GOASTED_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(helperEnv); mode != "" {
+		servePlugin(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// servePlugin is a plugin that flags calls to fmt.Println. In "old" mode it
+// answers with a protocol version from the future, in "hang" mode never.
+func servePlugin(mode string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if mode == "hang" {
+			time.Sleep(time.Hour)
+		}
+
+		var req Request
+		resp := Response{Version: req.Version}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = err.Error()
+		}
+		resp.Version = req.Version
+		if mode == "old" {
+			resp.Version = ProtocolVersion + 1
+		}
+
+		for _, use := range req.Uses {
+			if use.Object == "fmt.Println" {
+				resp.Violations = append(resp.Violations, Violation{
+					Line:     use.Line,
+					Column:   use.Column,
+					Message:  "fmt.Println in package " + req.Package.Path + " (" + req.Package.Name + ")",
+					Severity: "warning",
+				})
+			}
+		}
+		if !strings.Contains(req.Source, "package demo") {
+			resp.Error = "source not sent"
+		}
+
+		data, _ := json.Marshal(resp)
+		_, _ = os.Stdout.Write(append(data, '\n'))
+	}
+}
+
+// checkSource type-checks src as a file of package example.com/demo and
+// runs rule on it
+func checkSource(t *testing.T, rule *Rule, src string) []rules.Violation {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "demo.go")
+	if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("example.com/demo", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatalf("Failed to type-check: %v", err)
+	}
+
+	return rule.Check(&rules.Context{
+		FileSet:      fset,
+		File:         file,
+		Filename:     filename,
+		TypeInfo:     info,
+		Package:      pkg,
+		PackageFiles: []string{filename},
+	})
+}
+
+// newHelperRule returns a Rule running the test binary as a plugin
+func newHelperRule(t *testing.T, mode string) *Rule {
+	t.Helper()
+	t.Setenv(helperEnv, mode)
+
+	rule := New("no-println", config.PluginConfig{
+		Command:  []string{os.Args[0]},
+		Severity: "info",
+		Send:     []string{config.SendSource, config.SendUses},
+	})
+	t.Cleanup(func() {
+		if err := rule.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	})
+	return rule
+}
+
+const demoSource = `package demo
+
+import "fmt"
+
+func Hello() {
+	fmt.Println("hello")
+	fmt.Sprintf("%d", 1)
+}
+`
+
+func TestRule_ReportsPluginViolations(t *testing.T) {
+	rule := newHelperRule(t, "ok")
+
+	if rule.DefaultSeverity() != rules.SeverityInfo {
+		t.Errorf("Expected configured severity info, got %s", rule.DefaultSeverity())
+	}
+
+	// The process is reused across checks
+	for i := 0; i < 2; i++ {
+		violations := checkSource(t, rule, demoSource)
+		if len(violations) != 1 {
+			t.Fatalf("Expected 1 violation, got %d: %v", len(violations), violations)
+		}
+		v := violations[0]
+		if v.Line != 6 || v.Column != 6 || v.Rule != "no-println" || v.Severity != rules.SeverityWarning {
+			t.Errorf("Unexpected violation: %+v", v)
+		}
+		if v.Message != "fmt.Println in package example.com/demo (demo)" {
+			t.Errorf("Unexpected message: %q", v.Message)
+		}
+	}
+}
+
+func TestRule_RejectsOtherProtocolVersions(t *testing.T) {
+	rule := newHelperRule(t, "old")
+
+	violations := checkSource(t, rule, demoSource)
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "protocol version 2, expected 1") {
+		t.Fatalf("Expected a protocol version failure, got %v", violations)
+	}
+	if violations[0].Severity != rules.SeverityError {
+		t.Errorf("Expected failures to be errors, got %s", violations[0].Severity)
+	}
+}
+
+func TestRule_KillsPluginOutOfTime(t *testing.T) {
+	rule := newHelperRule(t, "hang")
+	rule.SetTimeout(100 * time.Millisecond)
+
+	start := time.Now()
+	violations := checkSource(t, rule, demoSource)
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "no response within 100ms") {
+		t.Fatalf("Expected a timeout failure, got %v", violations)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the check to give up after the timeout, took %s", elapsed)
+	}
+
+	// The killed plugin isn't asked again
+	violations = checkSource(t, rule, demoSource)
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "no response within") {
+		t.Errorf("Expected later checks to report the timeout, got %v", violations)
+	}
+}
+
+func TestRule_MissingExecutable(t *testing.T) {
+	rule := New("missing", config.PluginConfig{Command: []string{filepath.Join(t.TempDir(), "missing")}})
+
+	violations := checkSource(t, rule, demoSource)
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "failed to start plugin") {
+		t.Errorf("Expected a start failure, got %v", violations)
+	}
+}
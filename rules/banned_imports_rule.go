@@ -0,0 +1,199 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// File kinds a BannedImport can be restricted to
+const (
+	BannedInTests    = "test"
+	BannedInNonTests = "non-test"
+)
+
+// BannedImport is an entry of the banned-imports rule
+type BannedImport struct {
+	// Prefix is the banned import path. Subpackages are banned too.
+	Prefix string `json:"prefix"`
+
+	// Files restricts the ban to "test" or "non-test" files. Empty bans the
+	// import everywhere.
+	Files string `json:"files"`
+
+	// AllowedIn lists path globs of files that may still use the import.
+	// Relative globs are relative to the configuration file, like its
+	// include and exclude globs.
+	AllowedIn []string `json:"allowed-in"`
+
+	// Message replaces the default message
+	Message string `json:"message"`
+
+	// Replacement is what to use instead, e.g. "log/slog". It is only
+	// mentioned in the message: no fix is suggested, since the replacement
+	// rarely has the same API.
+	Replacement string `json:"replacement"`
+}
+
+// BannedImportsRule reports imports listed in its configuration. It bans
+// nothing until configured.
+type BannedImportsRule struct {
+	imports []BannedImport
+}
+
+// NewBannedImportsRule creates a new BannedImportsRule banning imports
+func NewBannedImportsRule(imports ...BannedImport) BannedImportsRule {
+	return BannedImportsRule{imports: imports}
+}
+
+// Name returns the rule name
+func (r BannedImportsRule) Name() string {
+	return "banned-imports"
+}
+
+// Description returns the rule description
+func (r BannedImportsRule) Description() string {
+	return "Detects imports banned by the project configuration"
+}
+
+// DefaultSeverity returns the rule's default severity
+func (r BannedImportsRule) DefaultSeverity() Severity {
+	return SeverityError
+}
+
+// Doc returns the rule's long-form documentation
+func (r BannedImportsRule) Doc() Doc {
+	return Doc{
+		Category:  "dependencies",
+		Rationale: "Every team has packages it moved away from. List them in the config and stop catching them in review.",
+		Bad: `import "github.com/sirupsen/logrus"
+
+logrus.WithField("user", id).Info("logged in")`,
+		Good: `import "log/slog"
+
+slog.Info("logged in", "user", id)`,
+		Anchor: "4-banned-imports---your-list-not-ours",
+	}
+}
+
+// Configure returns a copy of the rule banning the imports listed in the
+// "imports" option, with AllowedIn globs relative to the working directory
+func (r BannedImportsRule) Configure(options map[string]any) (Checker, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return r.ConfigureInDir(dir, options)
+}
+
+// ConfigureInDir returns a copy of the rule banning the imports listed in the
+// "imports" option, with AllowedIn globs relative to dir
+func (r BannedImportsRule) ConfigureInDir(dir string, options map[string]any) (Checker, error) {
+	var parsed struct {
+		Imports []BannedImport `json:"imports"`
+	}
+
+	// Options come from YAML or JSON; a JSON round trip gives both the same
+	// strict decoding
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	for i, banned := range parsed.Imports {
+		if banned.Prefix == "" {
+			return nil, fmt.Errorf("imports[%d]: prefix is required", i)
+		}
+		switch banned.Files {
+		case "", BannedInTests, BannedInNonTests:
+		default:
+			return nil, fmt.Errorf("imports[%d]: invalid files %q (valid options: %s, %s)", i, banned.Files, BannedInTests, BannedInNonTests)
+		}
+		for j, glob := range banned.AllowedIn {
+			if err := ValidateGlob(glob); err != nil {
+				return nil, fmt.Errorf("imports[%d]: %w", i, err)
+			}
+			parsed.Imports[i].AllowedIn[j] = AnchorGlob(dir, glob)
+		}
+	}
+
+	return NewBannedImportsRule(parsed.Imports...), nil
+}
+
+// Check checks the file's imports against the banned list
+func (r BannedImportsRule) Check(ctx *Context) []Violation {
+	var violations []Violation
+
+	for _, spec := range ctx.File.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		banned := r.find(ctx.Filename, importPath)
+		if banned == nil {
+			continue
+		}
+
+		message := banned.Message
+		if message == "" {
+			message = "File imports banned package: " + importPath
+		}
+		if banned.Replacement != "" {
+			message += " (use " + banned.Replacement + " instead)"
+		}
+
+		pos := ctx.FileSet.Position(spec.Pos())
+		violations = append(violations, Violation{
+			File:    ctx.Filename,
+			Line:    pos.Line,
+			Column:  pos.Column,
+			Rule:    r.Name(),
+			Message: message,
+		})
+	}
+
+	return violations
+}
+
+// find returns the first entry banning importPath in filename, or nil
+func (r BannedImportsRule) find(filename, importPath string) *BannedImport {
+	isTest := strings.HasSuffix(filename, "_test.go")
+	for i := range r.imports {
+		banned := &r.imports[i]
+		if importPath != banned.Prefix && !strings.HasPrefix(importPath, strings.TrimSuffix(banned.Prefix, "/")+"/") {
+			continue
+		}
+		if banned.Files == BannedInTests && !isTest || banned.Files == BannedInNonTests && isTest {
+			continue
+		}
+		if banned.allowed(filename) {
+			continue
+		}
+		return banned
+	}
+	return nil
+}
+
+// allowed reports whether filename matches one of the AllowedIn globs
+func (b *BannedImport) allowed(filename string) bool {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	name := filepath.ToSlash(abs)
+	for _, glob := range b.AllowedIn {
+		if MatchGlob(glob, name) {
+			return true
+		}
+	}
+	return false
+}
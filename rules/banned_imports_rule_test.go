@@ -0,0 +1,139 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+const bannedImportsSource = `package service
+
+import (
+	"io/ioutil"
+	"log/slog"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/errorsx"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+`
+
+func configureBannedImports(t *testing.T, options map[string]any) Checker {
+	t.Helper()
+
+	rule, err := NewBannedImportsRule().Configure(options)
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	return rule
+}
+
+func TestBannedImportsRule_BansNothingByDefault(t *testing.T) {
+	ctx := parseTestCode(t, "service.go", bannedImportsSource)
+
+	if violations := NewBannedImportsRule().Check(ctx); len(violations) != 0 {
+		t.Errorf("Expected 0 violations without configuration, got %d", len(violations))
+	}
+}
+
+func TestBannedImportsRule_DetectsPrefixes(t *testing.T) {
+	rule := configureBannedImports(t, map[string]any{
+		"imports": []any{
+			map[string]any{"prefix": "github.com/pkg/errors", "replacement": "errors"},
+			map[string]any{"prefix": "io/ioutil", "message": "io/ioutil is deprecated"},
+			map[string]any{"prefix": "github.com/sirupsen/logrus"},
+		},
+	})
+
+	ctx := parseTestCode(t, "service.go", bannedImportsSource)
+	violations := rule.(BannedImportsRule).Check(ctx)
+
+	if len(violations) != 3 {
+		t.Fatalf("Expected 3 violations, got %d: %v", len(violations), violations)
+	}
+	want := []string{
+		"io/ioutil is deprecated",
+		"File imports banned package: github.com/pkg/errors (use errors instead)",
+		"File imports banned package: github.com/sirupsen/logrus/hooks/test",
+	}
+	for i, v := range violations {
+		if v.Message != want[i] {
+			t.Errorf("Expected message %q, got %q", want[i], v.Message)
+		}
+		if v.Rule != "banned-imports" {
+			t.Errorf("Expected rule 'banned-imports', got '%s'", v.Rule)
+		}
+	}
+	if violations[0].Line != 4 {
+		t.Errorf("Expected first violation on line 4, got %d", violations[0].Line)
+	}
+}
+
+func TestBannedImportsRule_RestrictsFileKinds(t *testing.T) {
+	rule := configureBannedImports(t, map[string]any{
+		"imports": []any{
+			map[string]any{"prefix": "io/ioutil", "files": "test"},
+			map[string]any{"prefix": "github.com/pkg/errors", "files": "non-test"},
+		},
+	}).(BannedImportsRule)
+
+	violations := rule.Check(parseTestCode(t, "service_test.go", bannedImportsSource))
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "io/ioutil") {
+		t.Errorf("Expected only io/ioutil in test files, got %v", violations)
+	}
+
+	violations = rule.Check(parseTestCode(t, "service.go", bannedImportsSource))
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "github.com/pkg/errors") {
+		t.Errorf("Expected only github.com/pkg/errors in non-test files, got %v", violations)
+	}
+}
+
+func TestBannedImportsRule_AllowedIn(t *testing.T) {
+	rule, err := NewBannedImportsRule().ConfigureInDir("/repo", map[string]any{
+		"imports": []any{
+			map[string]any{"prefix": "io/ioutil", "allowed-in": []any{"internal/legacy/**", "./tools/*.go", "/abs/gen/*.go"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ConfigureInDir failed: %v", err)
+	}
+
+	tests := []struct {
+		filename string
+		want     int
+	}{
+		{"/repo/internal/legacy/adapter/x.go", 0},
+		{"/repo/tools/x.go", 0},
+		{"/abs/gen/x.go", 0},
+		{"/other/internal/legacy/x.go", 1},
+		{"/repo/sub/internal/legacy/x.go", 1},
+		{"/repo/abs/gen/x.go", 1},
+		{"/repo/internal/x.go", 1},
+	}
+	for _, tt := range tests {
+		if got := len(rule.(BannedImportsRule).Check(parseTestCode(t, tt.filename, bannedImportsSource))); got != tt.want {
+			t.Errorf("%s: expected %d violations, got %d", tt.filename, tt.want, got)
+		}
+	}
+}
+
+func TestBannedImportsRule_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]any
+		want    string
+	}{
+		{"missing prefix", map[string]any{"imports": []any{map[string]any{"message": "m"}}}, "prefix is required"},
+		{"bad files", map[string]any{"imports": []any{map[string]any{"prefix": "x", "files": "tests"}}}, `invalid files "tests"`},
+		{"bad glob", map[string]any{"imports": []any{map[string]any{"prefix": "x", "allowed-in": []any{"[a"}}}}, "invalid glob"},
+		{"unknown key", map[string]any{"imports": []any{map[string]any{"prefx": "x"}}}, "prefx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBannedImportsRule().Configure(tt.options)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
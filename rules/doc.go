@@ -0,0 +1,80 @@
+package rules
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DocBaseURL is where rule documentation anchors are resolved
+const DocBaseURL = "https://github.com/Arneball/goasted#"
+
+// Doc is the long-form documentation of a rule, as printed by
+// "goasted explain"
+type Doc struct {
+	// Category groups related rules, e.g. "testing" or "database"
+	Category string
+
+	// Rationale explains why the rule exists
+	Rationale string
+
+	// Bad and Good are Go snippets showing code the rule flags and how to
+	// fix it
+	Bad  string
+	Good string
+
+	// Anchor is the README section documenting the rule
+	Anchor string
+}
+
+// URL returns the link to the rule's documentation, or "" without an anchor
+func (d Doc) URL() string {
+	if d.Anchor == "" {
+		return ""
+	}
+	return DocBaseURL + d.Anchor
+}
+
+// Documented is implemented by rules that provide long-form documentation
+type Documented interface {
+	Doc() Doc
+}
+
+// DocOf returns the documentation of a rule. Rules that don't implement
+// Documented get their description as rationale.
+func DocOf(rule Checker) Doc {
+	if documented, ok := rule.(Documented); ok {
+		return documented.Doc()
+	}
+	return Doc{Rationale: rule.Description()}
+}
+
+// Explain writes the full documentation of a rule, as printed by
+// "goasted explain"
+func Explain(w io.Writer, rule Checker) {
+	doc := DocOf(rule)
+
+	_, _ = fmt.Fprintf(w, "%s - %s\n\n", rule.Name(), rule.Description())
+	if doc.Category != "" {
+		_, _ = fmt.Fprintf(w, "Category:         %s\n", doc.Category)
+	}
+	_, _ = fmt.Fprintf(w, "Default severity: %s\n", rule.DefaultSeverity())
+
+	if doc.Rationale != "" {
+		_, _ = fmt.Fprintf(w, "\n%s\n", doc.Rationale)
+	}
+	if doc.Bad != "" {
+		_, _ = fmt.Fprintf(w, "\nBad:\n%s\n", indent(doc.Bad))
+	}
+	if doc.Good != "" {
+		_, _ = fmt.Fprintf(w, "\nGood:\n%s\n", indent(doc.Good))
+	}
+	if url := doc.URL(); url != "" {
+		_, _ = fmt.Fprintf(w, "\nMore: %s\n", url)
+	}
+}
+
+// indent indents every line of a code snippet
+func indent(code string) string {
+	return "    " + strings.ReplaceAll(code, "\n", "\n    ")
+}
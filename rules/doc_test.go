@@ -0,0 +1,61 @@
+package rules
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// readmeAnchors returns the GitHub anchors of the README's headings
+func readmeAnchors(t *testing.T) map[string]bool {
+	t.Helper()
+
+	data, err := os.ReadFile("../README.md")
+	if err != nil {
+		t.Fatalf("Failed to read README: %v", err)
+	}
+
+	punctuation := regexp.MustCompile(`[^a-z0-9 _-]`)
+	anchors := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		heading := strings.ToLower(strings.TrimSpace(strings.TrimLeft(line, "#")))
+		anchors[strings.ReplaceAll(punctuation.ReplaceAllString(heading, ""), " ", "-")] = true
+	}
+	return anchors
+}
+
+func TestDefaultRules_AreDocumented(t *testing.T) {
+	anchors := readmeAnchors(t)
+
+	for _, rule := range DefaultRegistry().GetCheckers() {
+		if _, ok := rule.(Documented); !ok {
+			t.Errorf("Expected %s to implement Documented", rule.Name())
+			continue
+		}
+		doc := DocOf(rule)
+		if doc.Category == "" || doc.Rationale == "" || doc.Bad == "" || doc.Good == "" {
+			t.Errorf("Expected full documentation for %s, got %+v", rule.Name(), doc)
+		}
+		if !anchors[doc.Anchor] {
+			t.Errorf("Expected README heading for %s anchor %q", rule.Name(), doc.Anchor)
+		}
+	}
+}
+
+func TestDocOf_FallsBackToDescription(t *testing.T) {
+	doc := DocOf(undocumentedRule{})
+	if doc.Rationale != "undocumented" || doc.URL() != "" {
+		t.Errorf("Expected description as rationale and no URL, got %+v", doc)
+	}
+}
+
+// undocumentedRule implements only the Checker interface
+type undocumentedRule struct{}
+
+func (undocumentedRule) Name() string              { return "undocumented" }
+func (undocumentedRule) Description() string       { return "undocumented" }
+func (undocumentedRule) DefaultSeverity() Severity { return SeverityInfo }
@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// AnchorGlob makes a glob that is relative to dir absolute. Globs use
+// forward slashes.
+func AnchorGlob(dir, glob string) string {
+	if strings.HasPrefix(glob, "/") {
+		return glob
+	}
+	return filepath.ToSlash(dir) + "/" + strings.TrimPrefix(glob, "./")
+}
+
+// ValidateGlob checks that every segment of glob is a valid path.Match
+// pattern
+func ValidateGlob(glob string) error {
+	for _, segment := range strings.Split(glob, "/") {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+	return nil
+}
+
+// MatchGlob matches a slash-separated path against a glob where "**" matches
+// any number of path segments and other segments follow path.Match. A glob
+// that matches a directory also matches everything below it.
+func MatchGlob(glob, name string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against glob segments
+func matchSegments(glob, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			// Try to match the rest of the glob at every remaining position
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(glob[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], name[0]); !ok {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+
+	// The glob matched a directory prefix of name
+	return true
+}
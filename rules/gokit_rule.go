@@ -8,6 +8,11 @@ import (
 // GokitRule checks if code is using github.com/go-kit/kit
 type GokitRule struct{}
 
+// NewGokitRule creates a new GokitRule
+func NewGokitRule() GokitRule {
+	return GokitRule{}
+}
+
 // Name returns the rule name
 func (r GokitRule) Name() string {
 	return "gokit-usage"
@@ -18,6 +23,27 @@ func (r GokitRule) Description() string {
 	return "Detects usage of github.com/go-kit/kit in code"
 }
 
+// DefaultSeverity returns the rule's default severity
+func (r GokitRule) DefaultSeverity() Severity {
+	return SeverityError
+}
+
+// Doc returns the rule's long-form documentation
+func (r GokitRule) Doc() Doc {
+	return Doc{
+		Category:  "dependencies",
+		Rationale: "We don't want go-kit here. It's bloated Java-style over-engineering. Go is supposed to be simple.",
+		Bad: `import "github.com/go-kit/kit/endpoint"
+
+var getUser endpoint.Endpoint = makeGetUserEndpoint(svc)`,
+		Good: `func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+    user, err := h.svc.GetUser(r.Context(), r.PathValue("id"))
+    // ...
+}`,
+		Anchor: "3-gokit-usage---no-go-kit",
+	}
+}
+
 // Check checks the file for all gokit imports and usages
 func (r GokitRule) Check(ctx *Context) []Violation {
 	var violations []Violation
@@ -0,0 +1,33 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+	"github.com/Arneball/goasted/ruletest"
+)
+
+func TestSqlContextRule_Golden(t *testing.T) {
+	ruletest.RunWithSuggestedFixes(t, ruletest.TestData(), rules.NewSqlContextRule(), "sqlcontext")
+}
+
+func TestGokitRule_Golden(t *testing.T) {
+	ruletest.Run(t, ruletest.TestData(), rules.NewGokitRule(), "gokit")
+}
+
+func TestTestifyRule_Golden(t *testing.T) {
+	ruletest.Run(t, ruletest.TestData(), rules.NewTestifyRule(), "testify")
+}
+
+func TestBannedImportsRule_Golden(t *testing.T) {
+	rule, err := rules.NewBannedImportsRule().Configure(map[string]any{
+		"imports": []any{
+			map[string]any{"prefix": "io/ioutil", "files": "non-test", "message": "io/ioutil is deprecated", "replacement": "os"},
+			map[string]any{"prefix": "github.com/pkg/errors"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	ruletest.Run(t, ruletest.TestData(), rule, "bannedimports")
+}
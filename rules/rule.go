@@ -1,6 +1,7 @@
 package rules
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
@@ -12,6 +13,26 @@ type Context struct {
 	File     *ast.File
 	Filename string
 	TypeInfo *types.Info // Type information for the file (may be nil)
+
+	// Package and PackageFiles describe the package the file belongs to.
+	// They may be nil when a file is analyzed on its own.
+	Package      *types.Package
+	PackageFiles []string
+}
+
+// PackageContext provides context information for rules that check a whole
+// package at once
+type PackageContext struct {
+	FileSet   *token.FileSet
+	Files     []*ast.File
+	Filenames []string // Filenames[i] is the name of Files[i]
+	Package   *types.Package
+	TypesInfo *types.Info
+
+	// Imports is the import graph of all loaded packages and their
+	// dependencies, mapping each package path to the paths it imports
+	// directly
+	Imports map[string][]string
 }
 
 // Violation represents a rule violation
@@ -21,40 +42,163 @@ type Violation struct {
 	Column  int
 	Rule    string
 	Message string
+
+	// Severity of the violation. Rules normally leave this unset, and the
+	// analyzer fills in the rule's default or the configured severity.
+	Severity Severity
+
+	// SuggestedFixes optionally describes mechanical ways to repair the violation
+	SuggestedFixes []SuggestedFix
+
+	// Configurations lists the build configurations, such as "linux/amd64",
+	// the violation was found in. It is empty unless several configurations
+	// are analyzed.
+	Configurations []string
+}
+
+// SuggestedFix is a set of edits that together repair a violation
+type SuggestedFix struct {
+	Message string
+	Edits   []TextEdit
 }
 
-// Rule defines the interface that all rules must implement
-type Rule interface {
+// TextEdit replaces the bytes [Start, End) of File with NewText.
+// An insertion has Start == End.
+type TextEdit struct {
+	File    string
+	Start   int
+	End     int
+	NewText string
+}
+
+// Checker describes a rule independently of what it checks. Every rule
+// implements Checker together with Rule, PackageRule or SSARule.
+type Checker interface {
 	// Name returns the unique name of the rule
 	Name() string
 
 	// Description returns a human-readable description of the rule
 	Description() string
 
+	// DefaultSeverity returns the severity of the rule's violations unless
+	// the configuration overrides it
+	DefaultSeverity() Severity
+}
+
+// Rule defines the interface for rules that check one file at a time
+type Rule interface {
+	Checker
+
 	// Check checks a file and returns all violations found
 	Check(ctx *Context) []Violation
 }
 
+// PackageRule defines the interface for rules that need to see a whole
+// package at once, e.g. to relate declarations across files
+type PackageRule interface {
+	Checker
+
+	// CheckPackage checks a package and returns all violations found
+	CheckPackage(ctx *PackageContext) []Violation
+}
+
+// Configurable is implemented by rules that accept options from the
+// project configuration file
+type Configurable interface {
+	// Configure returns a copy of the rule configured with options
+	Configure(options map[string]any) (Checker, error)
+}
+
+// ConfigurableInDir is implemented by configurable rules whose options hold
+// paths, which are relative to the configuration file that set the options
+type ConfigurableInDir interface {
+	// ConfigureInDir returns a copy of the rule configured with options,
+	// resolving relative paths against dir
+	ConfigureInDir(dir string, options map[string]any) (Checker, error)
+}
+
+// Fingerprinted is implemented by rules whose behaviour depends on more than
+// the goasted binary and their configuration options, such as rules declared
+// in configuration files. Cached results are only reused while the
+// fingerprint is unchanged.
+type Fingerprinted interface {
+	// Fingerprint identifies the rule's behaviour. An empty fingerprint
+	// means the rule's results must never be cached.
+	Fingerprint() string
+}
+
 // Registry manages a collection of rules
-type Registry []Rule
+type Registry []Checker
 
 // NewRegistry creates a new rule registry
 func NewRegistry() *Registry {
 	return &Registry{}
 }
 
-// Register registers a new rule
-func (r *Registry) Register(rule Rule) {
+// DefaultRegistry creates a registry containing all built-in rules
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(NewTestifyRule())
+	registry.Register(NewSqlContextRule())
+	registry.Register(NewGokitRule())
+	registry.Register(NewBannedImportsRule())
+	return registry
+}
+
+// Register registers a new rule. It panics unless the rule implements Rule,
+// PackageRule or SSARule, since the analyzer would never run it otherwise.
+func (r *Registry) Register(rule Checker) {
+	switch rule.(type) {
+	case Rule, PackageRule, SSARule:
+	default:
+		panic(fmt.Sprintf("rules: %s implements neither Rule, PackageRule nor SSARule", rule.Name()))
+	}
 	*r = append(*r, rule)
 }
 
-// GetRules returns all registered rules
+// GetRules returns all registered rules that check one file at a time
 func (r *Registry) GetRules() []Rule {
-	return *r
+	var fileRules []Rule
+	for _, rule := range *r {
+		if fileRule, ok := rule.(Rule); ok {
+			fileRules = append(fileRules, fileRule)
+		}
+	}
+	return fileRules
 }
 
-// GetRule returns a rule by name, or nil if not found
+// GetRule returns a rule that checks one file at a time by name, or nil if
+// not found
 func (r *Registry) GetRule(name string) Rule {
+	fileRule, _ := r.GetChecker(name).(Rule)
+	return fileRule
+}
+
+// GetPackageRules returns all registered rules that check a whole package
+func (r *Registry) GetPackageRules() []PackageRule {
+	var pkgRules []PackageRule
+	for _, rule := range *r {
+		if pkgRule, ok := rule.(PackageRule); ok {
+			pkgRules = append(pkgRules, pkgRule)
+		}
+	}
+	return pkgRules
+}
+
+// GetPackageRule returns a rule that checks a whole package by name, or nil
+// if not found
+func (r *Registry) GetPackageRule(name string) PackageRule {
+	pkgRule, _ := r.GetChecker(name).(PackageRule)
+	return pkgRule
+}
+
+// GetCheckers returns all registered rules, whatever they check
+func (r *Registry) GetCheckers() []Checker {
+	return *r
+}
+
+// GetChecker returns a rule by name whatever it checks, or nil if not found
+func (r *Registry) GetChecker(name string) Checker {
 	for _, rule := range *r {
 		if rule.Name() == name {
 			return rule
@@ -67,7 +211,7 @@ func (r *Registry) GetRule(name string) Rule {
 func (r *Registry) Filter(names []string) *Registry {
 	filtered := NewRegistry()
 	for _, name := range names {
-		if rule := r.GetRule(name); rule != nil {
+		if rule := r.GetChecker(name); rule != nil {
 			filtered.Register(rule)
 		}
 	}
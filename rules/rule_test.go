@@ -0,0 +1,49 @@
+package rules
+
+import (
+	"testing"
+)
+
+type stubChecker struct{ name string }
+
+func (c stubChecker) Name() string              { return c.name }
+func (c stubChecker) Description() string       { return "stub" }
+func (c stubChecker) DefaultSeverity() Severity { return SeverityWarning }
+
+type stubPackageRule struct{ stubChecker }
+
+func (stubPackageRule) CheckPackage(*PackageContext) []Violation { return nil }
+
+func TestRegistry_Accessors(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewSqlContextRule())
+	registry.Register(stubPackageRule{stubChecker{"pkg"}})
+
+	if rules := registry.GetRules(); len(rules) != 1 || rules[0].Name() != "sql-context-required" {
+		t.Errorf("Expected only sql-context-required as a file rule, got %v", rules)
+	}
+	if rule := registry.GetRule("pkg"); rule != nil {
+		t.Errorf("Expected no file rule named pkg, got %v", rule)
+	}
+	if rules := registry.GetPackageRules(); len(rules) != 1 || rules[0].Name() != "pkg" {
+		t.Errorf("Expected only pkg as a package rule, got %v", rules)
+	}
+	if rule := registry.GetPackageRule("pkg"); rule == nil {
+		t.Errorf("Expected package rule pkg")
+	}
+	if checkers := registry.GetCheckers(); len(checkers) != 2 {
+		t.Errorf("Expected 2 rules, got %d", len(checkers))
+	}
+	if checker := registry.GetChecker("sql-context-required"); checker == nil {
+		t.Errorf("Expected rule sql-context-required")
+	}
+}
+
+func TestRegistry_RegisterRejectsUnknownKind(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected Register to panic for a rule that checks nothing")
+		}
+	}()
+	NewRegistry().Register(stubChecker{"nothing"})
+}
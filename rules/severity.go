@@ -0,0 +1,67 @@
+package rules
+
+import "fmt"
+
+// Severity indicates how serious a violation is
+type Severity int
+
+const (
+	// SeverityUnset means the violation takes the severity of its rule
+	SeverityUnset Severity = iota
+
+	// SeverityInfo is for informational findings
+	SeverityInfo
+
+	// SeverityWarning is for findings that should be fixed but don't block
+	SeverityWarning
+
+	// SeverityError is for findings that must be fixed
+	SeverityError
+)
+
+// String returns the lowercase name of the severity
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+// ParseSeverity parses "error", "warning" or "info"
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return SeverityUnset, fmt.Errorf("invalid severity %q (valid options: error, warning, info)", s)
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Severity) UnmarshalText(text []byte) error {
+	if len(text) == 0 || string(text) == "unset" {
+		*s = SeverityUnset
+		return nil
+	}
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
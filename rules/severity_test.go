@@ -0,0 +1,27 @@
+package rules
+
+import (
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityError} {
+		parsed, err := ParseSeverity(s.String())
+		if err != nil {
+			t.Errorf("Failed to parse %s: %v", s, err)
+		}
+		if parsed != s {
+			t.Errorf("Expected %s, got %s", s, parsed)
+		}
+	}
+
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Errorf("Expected error for invalid severity")
+	}
+}
+
+func TestSeverity_Ordering(t *testing.T) {
+	if !(SeverityInfo < SeverityWarning && SeverityWarning < SeverityError) {
+		t.Errorf("Expected info < warning < error")
+	}
+}
@@ -3,12 +3,19 @@ package rules
 import (
 	"go/ast"
 	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
 )
 
 // SqlContextRule checks if code calls sql.DB or sql.Tx methods without context
 // when a context-aware version exists
 type SqlContextRule struct{}
 
+// NewSqlContextRule creates a new SqlContextRule
+func NewSqlContextRule() SqlContextRule {
+	return SqlContextRule{}
+}
+
 // Name returns the rule name
 func (r SqlContextRule) Name() string {
 	return "sql-context-required"
@@ -19,6 +26,25 @@ func (r SqlContextRule) Description() string {
 	return "Detects calls to database/sql methods that should use context-aware versions"
 }
 
+// DefaultSeverity returns the rule's default severity
+func (r SqlContextRule) DefaultSeverity() Severity {
+	return SeverityError
+}
+
+// Doc returns the rule's long-form documentation
+func (r SqlContextRule) Doc() Doc {
+	return Doc{
+		Category: "database",
+		Rationale: "If you're making database calls without using context, you're doing it wrong. " +
+			"Context exists for a reason: timeouts, cancellation, tracing. Use it.",
+		Bad: `rows, err := db.Query("SELECT * FROM users")
+tx, err := db.Begin()`,
+		Good: `rows, err := db.QueryContext(ctx, "SELECT * FROM users")
+tx, err := db.BeginTx(ctx, nil)`,
+		Anchor: "2-sql-context-required---use-your-damn-context",
+	}
+}
+
 // methodsWithContextOverload maps method names to their context-aware equivalents
 var dbMethodsWithContextOverload = map[string]string{
 	"Exec":     "ExecContext",
@@ -92,11 +118,12 @@ func (r SqlContextRule) Check(ctx *Context) []Violation {
 		pos := ctx.FileSet.Position(callExpr.Pos())
 		typeName := exprType.String()
 		violations = append(violations, Violation{
-			File:    ctx.Filename,
-			Line:    pos.Line,
-			Column:  pos.Column,
-			Rule:    r.Name(),
-			Message: "Use " + contextMethod + " instead of " + methodName + " (called on " + getReceiverName(selExpr.X) + " of type " + typeName + ")",
+			File:           ctx.Filename,
+			Line:           pos.Line,
+			Column:         pos.Column,
+			Rule:           r.Name(),
+			Message:        "Use " + contextMethod + " instead of " + methodName + " (called on " + getReceiverName(selExpr.X) + " of type " + typeName + ")",
+			SuggestedFixes: r.suggestFix(ctx, callExpr, selExpr, contextMethod),
 		})
 
 		return true
@@ -105,6 +132,78 @@ func (r SqlContextRule) Check(ctx *Context) []Violation {
 	return violations
 }
 
+// suggestFix rewrites the call to its context-aware equivalent, passing the
+// context.Context parameter of the enclosing function. No fix is offered when
+// there is no such parameter in scope.
+func (r SqlContextRule) suggestFix(ctx *Context, call *ast.CallExpr, sel *ast.SelectorExpr, contextMethod string) []SuggestedFix {
+	ctxName := enclosingContextParam(ctx, call)
+	if ctxName == "" {
+		return nil
+	}
+
+	args := ctxName
+	if contextMethod == "BeginTx" {
+		args += ", nil"
+	}
+	if len(call.Args) > 0 {
+		args += ", "
+	}
+
+	lparen := ctx.FileSet.Position(call.Lparen).Offset + 1
+	return []SuggestedFix{{
+		Message: "Replace " + sel.Sel.Name + " with " + contextMethod,
+		Edits: []TextEdit{
+			{
+				File:    ctx.Filename,
+				Start:   ctx.FileSet.Position(sel.Sel.Pos()).Offset,
+				End:     ctx.FileSet.Position(sel.Sel.End()).Offset,
+				NewText: contextMethod,
+			},
+			{
+				File:    ctx.Filename,
+				Start:   lparen,
+				End:     lparen,
+				NewText: args,
+			},
+		},
+	}}
+}
+
+// enclosingContextParam returns the name of the innermost context.Context
+// parameter of a function enclosing node, or "" if there is none
+func enclosingContextParam(ctx *Context, node ast.Node) string {
+	path, _ := astutil.PathEnclosingInterval(ctx.File, node.Pos(), node.End())
+	for _, n := range path {
+		var fnType *ast.FuncType
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			fnType = fn.Type
+		case *ast.FuncLit:
+			fnType = fn.Type
+		default:
+			continue
+		}
+		for _, field := range fnType.Params.List {
+			for _, name := range field.Names {
+				if name.Name != "_" && isContextType(ctx.TypeInfo.TypeOf(name)) {
+					return name.Name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// isContextType checks if a type is context.Context
+func isContextType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
 // getReceiverName extracts a readable name from the receiver expression
 func getReceiverName(expr ast.Expr) string {
 	switch x := expr.(type) {
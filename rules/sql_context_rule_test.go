@@ -1,6 +1,7 @@
 package rules
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -214,3 +215,58 @@ func (r *Repository) Query() error {
 	}
 
 }
+
+func TestSqlContextRule_SuggestsFixWithContextParam(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+	"database/sql"
+)
+
+func example(ctx context.Context, db *sql.DB) {
+	db.Exec("SELECT 1")
+	db.Begin()
+}
+`
+
+	ctx := parseTestCodeWithTypes(t, "test.go", src)
+	rule := NewSqlContextRule()
+	violations := rule.Check(ctx)
+
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %d", len(violations))
+	}
+
+	want := []string{`db.ExecContext(ctx, "SELECT 1")`, `db.BeginTx(ctx, nil)`}
+	for i, v := range violations {
+		if len(v.SuggestedFixes) != 1 {
+			t.Fatalf("Expected 1 suggested fix, got %d", len(v.SuggestedFixes))
+		}
+		if got := applyEdits(src, v.SuggestedFixes[0].Edits); !strings.Contains(got, want[i]) {
+			t.Errorf("Expected fixed source to contain %s, got:\n%s", want[i], got)
+		}
+	}
+}
+
+func TestSqlContextRule_NoFixWithoutContextParam(t *testing.T) {
+	src := `package main
+
+import "database/sql"
+
+func example(db *sql.DB) {
+	db.Exec("SELECT 1")
+}
+`
+
+	ctx := parseTestCodeWithTypes(t, "test.go", src)
+	rule := NewSqlContextRule()
+	violations := rule.Check(ctx)
+
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, got %d", len(violations))
+	}
+	if len(violations[0].SuggestedFixes) != 0 {
+		t.Errorf("Expected no suggested fix without a context parameter, got %d", len(violations[0].SuggestedFixes))
+	}
+}
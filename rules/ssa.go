@@ -0,0 +1,28 @@
+package rules
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// SSAContext provides the SSA form of a package to rules that check value
+// flow rather than syntax, such as whether a *sql.Rows is always closed
+type SSAContext struct {
+	FileSet *token.FileSet
+	Program *ssa.Program
+	Package *ssa.Package
+
+	// Functions lists every function declared in the package's source,
+	// including methods and function literals, with their bodies built
+	Functions []*ssa.Function
+}
+
+// SSARule defines the interface for rules that check the SSA form of a
+// package. SSA is only built when at least one SSARule is enabled.
+type SSARule interface {
+	Checker
+
+	// CheckSSA checks a package and returns all violations found
+	CheckSSA(ctx *SSAContext) []Violation
+}
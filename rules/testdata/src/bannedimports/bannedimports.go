@@ -0,0 +1,14 @@
+package bannedimports
+
+import (
+	"io/ioutil" // want `io/ioutil is deprecated \(use os instead\)`
+
+	"github.com/pkg/errors" // want `File imports banned package: github.com/pkg/errors`
+)
+
+func read(name string) ([]byte, error) {
+	if name == "" {
+		return nil, errors.New("no name")
+	}
+	return ioutil.ReadFile(name)
+}
@@ -0,0 +1,10 @@
+package bannedimports
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	_, _ = ioutil.ReadFile("x")
+}
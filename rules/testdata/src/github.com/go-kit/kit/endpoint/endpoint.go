@@ -0,0 +1,5 @@
+package endpoint
+
+import "context"
+
+type Endpoint func(ctx context.Context, request any) (any, error)
@@ -0,0 +1,3 @@
+package errors
+
+func New(message string) error { return nil }
@@ -0,0 +1,11 @@
+package gokit
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint" // want `File imports go-kit package: github.com/go-kit/kit/endpoint`
+)
+
+var getUser endpoint.Endpoint = func(ctx context.Context, request any) (any, error) {
+	return nil, nil
+}
@@ -0,0 +1,33 @@
+package sqlcontext
+
+import (
+	"context"
+	"database/sql"
+)
+
+type store struct {
+	db *sql.DB
+}
+
+func (s *store) load(ctx context.Context, id int) error {
+	rows, err := s.db.Query("SELECT name FROM users WHERE id = ?", id) // want `Use QueryContext instead of Query \(called on db of type \*database/sql.DB\)`
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tx, err := s.db.Begin() // want `Use BeginTx instead of Begin`
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE users SET seen = true") // want `Use ExecContext instead of Exec \(called on tx of type \*database/sql.Tx\)`
+	return err
+}
+
+func (s *store) ping() error {
+	return s.db.Ping() // want `Use PingContext instead of Ping`
+}
+
+func (s *store) fine(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
@@ -0,0 +1,3 @@
+package testify
+
+func Add(a, b int) int { return a + b }
@@ -0,0 +1,11 @@
+package testify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert" // want `Test file imports testify package: github.com/stretchr/testify/assert`
+)
+
+func TestAdd(t *testing.T) {
+	assert.Equal(t, 3, Add(1, 2)) // want `Test code calls testify method: assert.Equal`
+}
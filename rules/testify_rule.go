@@ -9,6 +9,11 @@ import (
 // TestifyRule checks if test code is calling into github.com/stretchr/testify
 type TestifyRule struct{}
 
+// NewTestifyRule creates a new TestifyRule
+func NewTestifyRule() TestifyRule {
+	return TestifyRule{}
+}
+
 // Name returns the rule name
 func (r TestifyRule) Name() string {
 	return "testify-usage"
@@ -19,6 +24,29 @@ func (r TestifyRule) Description() string {
 	return "Detects usage of github.com/stretchr/testify in test files"
 }
 
+// DefaultSeverity returns the rule's default severity
+func (r TestifyRule) DefaultSeverity() Severity {
+	return SeverityError
+}
+
+// Doc returns the rule's long-form documentation
+func (r TestifyRule) Doc() Doc {
+	return Doc{
+		Category: "testing",
+		Rationale: "Stop being lazy. Go's standard testing package is perfectly fine. If you think " +
+			"`if err != nil { t.Errorf(...) }` is too verbose, you're in the wrong language.",
+		Bad: `func TestSomething(t *testing.T) {
+    assert.Equal(t, expected, actual)
+}`,
+		Good: `func TestSomething(t *testing.T) {
+    if actual != expected {
+        t.Errorf("got %v, want %v", actual, expected)
+    }
+}`,
+		Anchor: "1-testify-usage---no-testify-allowed",
+	}
+}
+
 // Check checks the file for all testify imports and usages
 func (r TestifyRule) Check(ctx *Context) []Violation {
 	// Only check test files
@@ -6,6 +6,7 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
+	"sort"
 	"testing"
 )
 
@@ -63,3 +64,13 @@ func parseTestCodeWithTypes(t *testing.T, filename string, src string) *Context
 
 	return ctx
 }
+
+// applyEdits applies text edits to src and returns the result
+func applyEdits(src string, edits []TextEdit) string {
+	sorted := append([]TextEdit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start > sorted[j].Start })
+	for _, e := range sorted {
+		src = src[:e.Start] + e.NewText + src[e.End:]
+	}
+	return src
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Arneball/goasted/config"
+	"github.com/Arneball/goasted/rules"
+)
+
+// runRules implements "goasted rules": list every registered rule with its
+// status from the configuration
+func runRules(args []string) int {
+	var path string
+
+	fs := flag.NewFlagSet("goasted rules", flag.ExitOnError)
+	fs.StringVar(&path, "path", ".", "Path whose configuration determines the rule status")
+	_ = fs.Parse(args)
+
+	a, err := newAnalyzer([]string{configDir(path)}, "all")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	defer closeAnalyzer(a)
+
+	registry, err := a.RegistryFor(configDir(path))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		return 1
+	}
+	cfg, err := config.NewResolver(registry).ForDir(configDir(path))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		return 1
+	}
+
+	listRules(os.Stdout, registry, cfg)
+	return 0
+}
+
+// listRules writes a table of the rules in registry, sorted by name
+func listRules(w io.Writer, registry *rules.Registry, cfg *config.Config) {
+	// Sort a copy: GetCheckers returns the registry's own slice
+	checkers := slices.Clone(registry.GetCheckers())
+	sort.Slice(checkers, func(i, j int) bool {
+		return checkers[i].Name() < checkers[j].Name()
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "RULE\tCATEGORY\tSEVERITY\tSTATUS\tDESCRIPTION")
+	for _, rule := range checkers {
+		status := "enabled"
+		if !cfg.Enabled(rule.Name()) {
+			status = "disabled"
+		}
+		severity := cfg.Severity(rule.Name())
+		if severity == rules.SeverityUnset {
+			severity = rule.DefaultSeverity()
+		}
+		category := rules.DocOf(rule).Category
+		if category == "" {
+			category = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", rule.Name(), category, severity, status, rule.Description())
+	}
+	_ = tw.Flush()
+
+	if len(cfg.Sources) > 0 {
+		_, _ = fmt.Fprintf(w, "\nConfiguration: %s\n", strings.Join(cfg.Sources, ", "))
+	}
+}
+
+// runExplain implements "goasted explain <rule>": print the rationale and
+// examples of a rule
+func runExplain(args []string) int {
+	if len(args) != 1 {
+		_, _ = fmt.Fprintln(os.Stderr, "Usage: goasted explain <rule>")
+		return 2
+	}
+
+	a, err := newAnalyzer([]string{"."}, "all")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	defer closeAnalyzer(a)
+
+	registry, err := a.RegistryFor(".")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		return 1
+	}
+
+	rule := registry.GetChecker(args[0])
+	if rule == nil {
+		var names []string
+		for _, r := range registry.GetCheckers() {
+			names = append(names, r.Name())
+		}
+		sort.Strings(names)
+		_, _ = fmt.Fprintf(os.Stderr, "Unknown rule: %s (known rules: %s)\n", args[0], strings.Join(names, ", "))
+		return 2
+	}
+
+	rules.Explain(os.Stdout, rule)
+	return 0
+}
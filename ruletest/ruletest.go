@@ -0,0 +1,47 @@
+// Package ruletest tests goasted rules against annotated testdata packages,
+// for rules in this repository and rules written outside of it.
+//
+// Test packages live in testdata/src, one directory per import path, and are
+// loaded in GOPATH mode with full type information. Dependencies that aren't
+// in the standard library are stubbed as packages under testdata/src too.
+// Every line expected to have a violation carries a comment
+//
+//	db.Query("SELECT 1") // want `Query without context`
+//
+// with one or more quoted regular expressions matched against the messages
+// reported on that line. Violations without a matching expectation and
+// expectations without a matching violation fail the test.
+//
+// Suggested fixes are checked against golden files: the expected content of
+// example.go after applying all fixes is example.go.golden. Violations are
+// checked as the rule reports them, before goasted's ignore directives and
+// configuration apply.
+package ruletest
+
+import (
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/Arneball/goasted/goanalysis"
+	"github.com/Arneball/goasted/rules"
+)
+
+// Testing is the part of *testing.T the harness reports failures to
+type Testing = analysistest.Testing
+
+// TestData returns the absolute path of the testdata directory next to the
+// calling test
+func TestData() string {
+	return analysistest.TestData()
+}
+
+// Run runs rule over the packages matching patterns in dir/src and checks
+// the violations against the want comments
+func Run(t Testing, dir string, rule rules.Checker, patterns ...string) {
+	analysistest.Run(t, dir, goanalysis.NewAnalyzer(rule), patterns...)
+}
+
+// RunWithSuggestedFixes is like Run, and also checks the result of applying
+// the suggested fixes to each file against its .golden file
+func RunWithSuggestedFixes(t Testing, dir string, rule rules.Checker, patterns ...string) {
+	analysistest.RunWithSuggestedFixes(t, dir, goanalysis.NewAnalyzer(rule), patterns...)
+}
@@ -0,0 +1,83 @@
+package ruletest
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+// printlnRule reports calls to the println builtin and suggests print
+type printlnRule struct{}
+
+func (printlnRule) Name() string                    { return "no-println" }
+func (printlnRule) Description() string             { return "Reports println calls" }
+func (printlnRule) DefaultSeverity() rules.Severity { return rules.SeverityWarning }
+
+func (r printlnRule) Check(ctx *rules.Context) []rules.Violation {
+	var violations []rules.Violation
+	ast.Inspect(ctx.File, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := call.Fun.(*ast.Ident)
+		if !ok || ident.Name != "println" {
+			return true
+		}
+		pos := ctx.FileSet.Position(call.Pos())
+		violations = append(violations, rules.Violation{
+			File:    ctx.Filename,
+			Line:    pos.Line,
+			Column:  pos.Column,
+			Rule:    r.Name(),
+			Message: fmt.Sprintf("println call with %d arguments", len(call.Args)),
+			SuggestedFixes: []rules.SuggestedFix{{
+				Message: "Use print",
+				Edits: []rules.TextEdit{{
+					File:    ctx.Filename,
+					Start:   pos.Offset,
+					End:     ctx.FileSet.Position(ident.End()).Offset,
+					NewText: "print",
+				}},
+			}},
+		})
+		return true
+	})
+	return violations
+}
+
+// recorder collects the failures the harness reports
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRun_MatchesWantComments(t *testing.T) {
+	Run(t, TestData(), printlnRule{}, "calls")
+}
+
+func TestRunWithSuggestedFixes_ComparesGoldenFiles(t *testing.T) {
+	RunWithSuggestedFixes(t, TestData(), printlnRule{}, "calls")
+}
+
+func TestRun_ReportsMismatches(t *testing.T) {
+	rec := &recorder{}
+	Run(rec, TestData(), printlnRule{}, "unannotated")
+
+	all := strings.Join(rec.errors, "\n")
+	if len(rec.errors) != 2 {
+		t.Fatalf("Expected 2 failures, got %d:\n%s", len(rec.errors), all)
+	}
+	if !strings.Contains(all, "unexpected diagnostic: println call with 1 arguments") {
+		t.Errorf("Expected the unannotated violation to be reported, got:\n%s", all)
+	}
+	if !strings.Contains(all, "no diagnostic was reported matching") {
+		t.Errorf("Expected the unmatched want comment to be reported, got:\n%s", all)
+	}
+}
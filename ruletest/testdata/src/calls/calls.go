@@ -0,0 +1,7 @@
+package calls
+
+func greet(name string) {
+	println("hello", name) // want `println call with 2 arguments`
+	print(name)
+	println() // want `println call with 0 arguments`
+}
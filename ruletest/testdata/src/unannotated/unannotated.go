@@ -0,0 +1,6 @@
+package unannotated
+
+func greet() {
+	println("hello")
+	print("world") // want `println call`
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Arneball/goasted/analyzer"
+)
+
+// slowestPackages is the number of packages listed by -stats
+const slowestPackages = 10
+
+// startProfiling starts writing a CPU profile and an execution trace to the
+// given files, if set. The returned function stops both.
+func startProfiling(cpuProfile, traceFile string) (func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			closeProfile(f)
+		})
+	}
+
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("failed to create trace: %w", err)
+		}
+		if err := trace.Start(f); err != nil {
+			_ = f.Close()
+			stop()
+			return nil, fmt.Errorf("failed to start trace: %w", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			closeProfile(f)
+		})
+	}
+
+	return stop, nil
+}
+
+// closeProfile closes a profile file, reporting failures on stderr
+func closeProfile(f *os.File) {
+	if err := f.Close(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to write %s: %v\n", f.Name(), err)
+	}
+}
+
+// printStats writes where the time of an analysis went: the phases, each
+// rule sorted by total time, and the packages slowest to type-check
+func printStats(w io.Writer, result *analyzer.Result, total time.Duration) {
+	var typeCheck time.Duration
+	for _, d := range result.TypeCheck {
+		typeCheck += d
+	}
+	var ruleTime time.Duration
+	for _, stats := range result.Rules {
+		ruleTime += stats.Duration
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintf(tw, "PHASE\tTIME\n")
+	_, _ = fmt.Fprintf(tw, "load\t%s\n", formatDuration(result.Load))
+	_, _ = fmt.Fprintf(tw, "type-check (%d packages, cumulative)\t%s\n", len(result.TypeCheck), formatDuration(typeCheck))
+	_, _ = fmt.Fprintf(tw, "rules (%d files, cumulative)\t%s\n", result.Files, formatDuration(ruleTime))
+	if result.CacheHits+result.CacheMisses > 0 {
+		_, _ = fmt.Fprintf(tw, "cache\t%d of %d packages\n", result.CacheHits, result.CacheHits+result.CacheMisses)
+	}
+	_, _ = fmt.Fprintf(tw, "total\t%s\n", formatDuration(total))
+	_ = tw.Flush()
+	_, _ = fmt.Fprintln(w)
+
+	names := make([]string, 0, len(result.Rules))
+	for name := range result.Rules {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := result.Rules[names[i]], result.Rules[names[j]]
+		if a.Duration != b.Duration {
+			return a.Duration > b.Duration
+		}
+		return names[i] < names[j]
+	})
+
+	_, _ = fmt.Fprintf(tw, "RULE\tRUNS\tTOTAL\tMAX\tVIOLATIONS\tSUPPRESSED\n")
+	for _, name := range names {
+		stats := result.Rules[name]
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\n", name, stats.Runs,
+			formatDuration(stats.Duration), formatDuration(stats.Max), stats.Violations, stats.Suppressed)
+	}
+	_ = tw.Flush()
+
+	if len(result.TypeCheck) == 0 {
+		return
+	}
+
+	pkgs := make([]string, 0, len(result.TypeCheck))
+	for pkg := range result.TypeCheck {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		a, b := result.TypeCheck[pkgs[i]], result.TypeCheck[pkgs[j]]
+		if a != b {
+			return a > b
+		}
+		return pkgs[i] < pkgs[j]
+	})
+	if len(pkgs) > slowestPackages {
+		pkgs = pkgs[:slowestPackages]
+	}
+
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(tw, "PACKAGE\tTYPE-CHECK\n")
+	for _, pkg := range pkgs {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", pkg, formatDuration(result.TypeCheck[pkg]))
+	}
+	_ = tw.Flush()
+}
+
+// formatDuration rounds d for display
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
@@ -0,0 +1,42 @@
+package watch
+
+import (
+	"github.com/Arneball/goasted/rules"
+)
+
+// identity identifies a violation across runs. Lines are left out, so that
+// violations moved by edits above them are not reported as fixed and
+// gained again.
+type identity struct {
+	file, rule, message string
+}
+
+// Diff compares the violations of two runs and returns those only found in
+// after, and those only found in before. Violations are matched by file,
+// rule and message; when several share these, only the difference in their
+// number is reported.
+func Diff(before, after []rules.Violation) (gained, fixed []rules.Violation) {
+	count := func(violations []rules.Violation) map[identity]int {
+		counts := make(map[identity]int)
+		for _, v := range violations {
+			counts[identity{v.File, v.Rule, v.Message}]++
+		}
+		return counts
+	}
+
+	// The last violations of a group are reported, as an added violation
+	// usually comes after the existing ones
+	excess := func(violations []rules.Violation, other map[identity]int) []rules.Violation {
+		seen := make(map[identity]int)
+		var extra []rules.Violation
+		for _, v := range violations {
+			id := identity{v.File, v.Rule, v.Message}
+			seen[id]++
+			if seen[id] > other[id] {
+				extra = append(extra, v)
+			}
+		}
+		return extra
+	}
+	return excess(after, count(before)), excess(before, count(after))
+}
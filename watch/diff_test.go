@@ -0,0 +1,38 @@
+package watch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Arneball/goasted/rules"
+)
+
+func TestDiff(t *testing.T) {
+	v := func(file string, line int, rule, message string) rules.Violation {
+		return rules.Violation{File: file, Line: line, Rule: rule, Message: message}
+	}
+
+	before := []rules.Violation{
+		v("a.go", 3, "sql-context-required", "Use ExecContext instead of Exec"),
+		v("a.go", 9, "sql-context-required", "Use QueryContext instead of Query"),
+		v("b.go", 1, "testify-usage", "Test file imports testify package"),
+	}
+	after := []rules.Violation{
+		// Moved down by an edit above it
+		v("a.go", 5, "sql-context-required", "Use ExecContext instead of Exec"),
+		v("a.go", 8, "sql-context-required", "Use ExecContext instead of Exec"),
+		v("b.go", 1, "testify-usage", "Test file imports testify package"),
+	}
+
+	gained, fixed := Diff(before, after)
+	if want := after[1:2]; !reflect.DeepEqual(gained, want) {
+		t.Errorf("Expected gained %v, got %v", want, gained)
+	}
+	if want := before[1:2]; !reflect.DeepEqual(fixed, want) {
+		t.Errorf("Expected fixed %v, got %v", want, fixed)
+	}
+
+	if gained, fixed := Diff(after, after); len(gained) != 0 || len(fixed) != 0 {
+		t.Errorf("Expected no difference, got %v and %v", gained, fixed)
+	}
+}
@@ -0,0 +1,199 @@
+package watch
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Graph relates the package directories below the watched trees by their
+// imports. It is built from the import declarations of all Go files, test
+// files and files behind build constraints included, so it may list more
+// importers than a build would but never fewer.
+type Graph struct {
+	// dirs maps each package directory to the paths its files import
+	dirs map[string][]string
+
+	// paths maps import paths to package directories
+	paths map[string]string
+}
+
+// LoadGraph builds the import graph of the packages below roots
+func LoadGraph(roots []string) (*Graph, error) {
+	state, err := Scan(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graph{dirs: make(map[string][]string), paths: make(map[string]string)}
+	modules := make(map[string]string)
+	fset := token.NewFileSet()
+
+	files := make([]string, 0, len(state))
+	for path := range state {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	for _, path := range files {
+		if filepath.Ext(path) != ".go" {
+			continue
+		}
+		dir := filepath.Dir(path)
+		if _, ok := g.dirs[dir]; !ok {
+			g.dirs[dir] = nil
+			if importPath := importPathOf(dir, modules); importPath != "" {
+				g.paths[importPath] = dir
+			}
+		}
+
+		// A file that doesn't parse yet still belongs to its package
+		file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if file == nil || err != nil && len(file.Imports) == 0 {
+			continue
+		}
+		for _, spec := range file.Imports {
+			if imported, err := strconv.Unquote(spec.Path.Value); err == nil {
+				g.dirs[dir] = append(g.dirs[dir], imported)
+			}
+		}
+	}
+	return g, nil
+}
+
+// importPathOf returns the import path of the package in dir, from the
+// nearest go.mod above it, or "" outside of a module. modules caches the
+// module path of each directory.
+func importPathOf(dir string, modules map[string]string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		module, ok := modules[d]
+		if !ok {
+			if data, err := os.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+				module = modfile.ModulePath(data)
+			}
+			modules[d] = module
+		}
+		if module != "" {
+			rel, err := filepath.Rel(d, dir)
+			if err != nil {
+				return ""
+			}
+			if rel == "." {
+				return module
+			}
+			return module + "/" + filepath.ToSlash(rel)
+		}
+		if parent := filepath.Dir(d); parent == d {
+			return ""
+		}
+	}
+}
+
+// Packages returns the package directories, sorted
+func (g *Graph) Packages() []string {
+	dirs := make([]string, 0, len(g.dirs))
+	for dir := range g.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Has reports whether dir is a package directory of the graph
+func (g *Graph) Has(dir string) bool {
+	_, ok := g.dirs[dir]
+	return ok
+}
+
+// Affected returns the directories of the changed files together with the
+// package directories importing them, directly or not, sorted
+func (g *Graph) Affected(changed []string) []string {
+	importers := make(map[string][]string)
+	for dir, imports := range g.dirs {
+		for _, path := range imports {
+			if imported, ok := g.paths[path]; ok && imported != dir {
+				importers[imported] = append(importers[imported], dir)
+			}
+		}
+	}
+
+	affected := make(map[string]bool)
+	var visit func(dir string)
+	visit = func(dir string) {
+		if affected[dir] {
+			return
+		}
+		affected[dir] = true
+		for _, importer := range importers[dir] {
+			visit(importer)
+		}
+	}
+	for _, path := range changed {
+		if filepath.Ext(path) == ".go" {
+			visit(filepath.Dir(path))
+		}
+	}
+
+	dirs := make([]string, 0, len(affected))
+	for dir := range affected {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Matches reports whether the package in pkgDir is matched by one of
+// patterns, interpreted like the go tool does relative to dir: directory
+// patterns such as ./... or ../pkg, import path patterns such as
+// example.com/m/... and .go files. Directory patterns ending in /... also
+// match nested modules.
+func (g *Graph) Matches(pkgDir, dir string, patterns []string) bool {
+	importPath := ""
+	for path, d := range g.paths {
+		if d == pkgDir {
+			importPath = path
+		}
+	}
+
+	for _, pattern := range patterns {
+		slashed := filepath.ToSlash(pattern)
+		switch {
+		case strings.HasSuffix(slashed, ".go"):
+			if abs, err := filepath.Abs(filepath.Join(dir, filepath.Dir(pattern))); err == nil && abs == pkgDir {
+				return true
+			}
+		case filepath.IsAbs(pattern) || slashed == "." || slashed == ".." || strings.HasPrefix(slashed, "./") || strings.HasPrefix(slashed, "../"):
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+			abs, err := filepath.Abs(pattern)
+			if err == nil && matchPattern(filepath.ToSlash(abs), filepath.ToSlash(pkgDir)) {
+				return true
+			}
+		default:
+			if importPath != "" && matchPattern(slashed, importPath) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPattern reports whether name matches pattern, where ... matches any
+// string and a trailing /... also matches nothing, as in the go tool
+func matchPattern(pattern, name string) bool {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	matched, err := regexp.MatchString("^"+re+"$", name)
+	return err == nil && matched
+}
@@ -0,0 +1,99 @@
+package watch
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGraph_Affected(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":           "module example.com/m\n",
+		"base/base.go":     "package base\n",
+		"mid/mid.go":       "package mid\n\nimport \"example.com/m/base\"\n",
+		"top/top.go":       "package top\n\nimport (\n\t\"fmt\"\n\t\"example.com/m/mid\"\n)\n",
+		"other/other.go":   "package other\n",
+		"tested/t_test.go": "package tested_test\n\nimport \"example.com/m/base\"\n",
+		"broken/broken.go": "package broken\n\nimport \"example.com/m/mid\"\n\nfunc {",
+
+		// A nested module importing the outer one
+		"nested/go.mod":   "module example.com/nested\n",
+		"nested/n/n.go":   "package n\n\nimport \"example.com/m/top\"\n",
+		"nested/solo.go":  "package nested\n",
+		"nested/x/x.go":   "package x\n\nimport \"example.com/nested/n\"\n",
+		"testdata/t/t.go": "package t\n\nimport \"example.com/m/base\"\n",
+	})
+
+	g, err := LoadGraph([]string{dir})
+	if err != nil {
+		t.Fatalf("LoadGraph failed: %v", err)
+	}
+	if !g.Has(filepath.Join(dir, "broken")) || g.Has(filepath.Join(dir, "testdata", "t")) {
+		t.Errorf("Unexpected packages: %v", g.Packages())
+	}
+
+	abs := func(rels ...string) []string {
+		var paths []string
+		for _, rel := range rels {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(rel)))
+		}
+		return paths
+	}
+
+	tests := []struct {
+		changed []string
+		want    []string
+	}{
+		{abs("base/base.go"), abs("base", "broken", "mid", "nested/n", "nested/x", "tested", "top")},
+		{abs("top/top.go"), abs("nested/n", "nested/x", "top")},
+		{abs("other/other.go", "go.mod"), abs("other")},
+		{abs("nested/x/x.go"), abs("nested/x")},
+		{abs("gone/gone.go"), abs("gone")},
+	}
+	for _, tt := range tests {
+		if got := g.Affected(tt.changed); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Affected(%v):\nexpected %v\ngot      %v", tt.changed, tt.want, got)
+		}
+	}
+}
+
+func TestGraph_Matches(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":        "module example.com/m\n",
+		"a/a.go":        "package a\n",
+		"a/b/b.go":      "package b\n",
+		"c/c.go":        "package c\n",
+		"nested/go.mod": "module example.com/nested\n",
+		"nested/n.go":   "package nested\n",
+	})
+	g, err := LoadGraph([]string{dir})
+	if err != nil {
+		t.Fatalf("LoadGraph failed: %v", err)
+	}
+
+	tests := []struct {
+		patterns []string
+		pkg      string
+		want     bool
+	}{
+		{[]string{"./..."}, "a/b", true},
+		{[]string{"./..."}, "nested", true},
+		{[]string{"./a"}, "a", true},
+		{[]string{"./a"}, "a/b", false},
+		{[]string{"./a/..."}, "c", false},
+		{[]string{"example.com/m/a/..."}, "a/b", true},
+		{[]string{"example.com/m/a"}, "a/b", false},
+		{[]string{"example.com/m/..."}, "nested", false},
+		{[]string{"example.com/nested"}, "nested", true},
+		{[]string{"c/c.go"}, "c", true},
+		{[]string{"./a", "c/c.go"}, "a/b", false},
+	}
+	for _, tt := range tests {
+		pkgDir := filepath.Join(dir, filepath.FromSlash(tt.pkg))
+		if got := g.Matches(pkgDir, dir, tt.patterns); got != tt.want {
+			t.Errorf("Matches(%s, %v): expected %v, got %v", tt.pkg, tt.patterns, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package watch detects changes to Go source trees and works out which
+// packages they affect, so that only those are analyzed again.
+//
+// Changes are found by polling: every interval the watched trees are walked
+// and the size and modification time of each relevant file is compared with
+// the previous walk. This needs no platform support and copes with editors
+// that replace files rather than writing them in place.
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Arneball/goasted/config"
+)
+
+// State is a snapshot of the watched files: their size and modification
+// time by path
+type State map[string]fileState
+
+// fileState is what tells a modified file apart
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Scan takes a snapshot of the Go files, module files and configuration
+// files below roots. Like the go tool it skips vendor and testdata
+// directories and those starting with . or _. Roots and directories
+// removed while scanning are left out.
+func Scan(roots []string) (State, error) {
+	state := make(State)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed since its parent was read, e.g. by switching
+				// branches
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && skipDir(d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !watched(d.Name()) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				// Removed since the directory was read
+				return nil
+			}
+			state[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// skipDir reports whether the go tool ignores a directory
+func skipDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// watched reports whether changes to a file are relevant
+func watched(name string) bool {
+	return strings.HasSuffix(name, ".go") || affectsAll(name)
+}
+
+// affectsAll reports whether a file affects the analysis of every package:
+// module files and goasted configuration files
+func affectsAll(name string) bool {
+	switch name {
+	case "go.mod", "go.sum", "go.work", "go.work.sum":
+		return true
+	}
+	return slices.Contains(config.FileNames, name)
+}
+
+// Changed returns the files added, removed or modified since old, sorted
+func (s State) Changed(old State) []string {
+	var changed []string
+	for path, st := range s {
+		if prev, ok := old[path]; !ok || prev != st {
+			changed = append(changed, path)
+		}
+	}
+	for path := range old {
+		if _, ok := s[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// NeedsFullRun reports whether changed includes files that affect every
+// package, so that everything must be analyzed again
+func NeedsFullRun(changed []string) bool {
+	for _, path := range changed {
+		if affectsAll(filepath.Base(path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watcher polls trees for changes
+type Watcher struct {
+	roots    []string
+	interval time.Duration
+	state    State
+}
+
+// New starts watching roots, polling every interval
+func New(roots []string, interval time.Duration) (*Watcher, error) {
+	state, err := Scan(roots)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{roots: roots, interval: interval, state: state}, nil
+}
+
+// Next waits for files to change and returns them. Changes are collected
+// until a poll finds nothing new, so that saving several files, or an
+// editor writing a file in steps, gives one result. It returns ctx's error
+// once ctx is done.
+func (w *Watcher) Next(ctx context.Context) ([]string, error) {
+	changed := make(map[string]bool)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		state, err := Scan(w.roots)
+		if err != nil {
+			return nil, err
+		}
+		files := state.Changed(w.state)
+		w.state = state
+
+		if len(files) == 0 && len(changed) > 0 {
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			return paths, nil
+		}
+		for _, path := range files {
+			changed[path] = true
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeFiles writes files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScan_Changed(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":           "module example.com/m\n",
+		"a/a.go":           "package a\n",
+		"a/notes.txt":      "ignored",
+		"b/b.go":           "package b\n",
+		"testdata/x.go":    "package x\n",
+		".hidden/h.go":     "package h\n",
+		"vendor/v/v.go":    "package v\n",
+		"c/.goasted.yaml":  "rules: {}\n",
+		"c/c_test.go":      "package c\n",
+		"_skipped/s/s.go":  "package s\n",
+		"a/sub/sub.go.txt": "not go",
+	})
+
+	before, err := Scan([]string{dir})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(before) != 5 {
+		t.Errorf("Expected go.mod, 3 Go files and a config file, got %v", before)
+	}
+
+	writeFiles(t, dir, map[string]string{"a/a.go": "package a\n\nvar X = 1\n", "d/d.go": "package d\n"})
+	if err := os.Remove(filepath.Join(dir, "b", "b.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := Scan([]string{dir})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "a", "a.go"), filepath.Join(dir, "b", "b.go"), filepath.Join(dir, "d", "d.go")}
+	if got := after.Changed(before); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected changes %v, got %v", want, got)
+	}
+	if got := after.Changed(after); len(got) != 0 {
+		t.Errorf("Expected no changes, got %v", got)
+	}
+}
+
+func TestNeedsFullRun(t *testing.T) {
+	tests := []struct {
+		changed []string
+		want    bool
+	}{
+		{[]string{"/m/a/a.go"}, false},
+		{[]string{"/m/a/a.go", "/m/go.mod"}, true},
+		{[]string{"/m/go.sum"}, true},
+		{[]string{"/m/sub/.goasted.yml"}, true},
+	}
+	for _, tt := range tests {
+		if got := NeedsFullRun(tt.changed); got != tt.want {
+			t.Errorf("NeedsFullRun(%v): expected %v, got %v", tt.changed, tt.want, got)
+		}
+	}
+}
+
+func TestWatcher_Next(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a/a.go": "package a\n"})
+
+	w, err := New([]string{dir}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		writeFiles(t, dir, map[string]string{"a/a.go": "package a\n\nvar X = 1\n", "a/b.go": "package a\n"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed, err := w.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "a", "a.go"), filepath.Join(dir, "a", "b.go")}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("Expected changes %v, got %v", want, changed)
+	}
+
+	// Without changes Next waits until ctx is done
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := w.Next(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to end the wait, got %v", err)
+	}
+}
+
+func TestWatcher_RemovedPackage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a/a.go": "package a\n", "b/b.go": "package b\n"})
+	pkg := filepath.Join(dir, "b")
+
+	// The package directory is watched as a root of its own, as packages
+	// given on the command line are
+	roots := []string{dir, pkg}
+	w, err := New(roots, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		if err := os.RemoveAll(pkg); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed, err := w.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if want := []string{filepath.Join(pkg, "b.go")}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Expected changes %v, got %v", want, changed)
+	}
+
+	if _, err := LoadGraph(roots); err != nil {
+		t.Errorf("Expected the graph to load without the removed package, got %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Arneball/goasted/formatter"
+	"github.com/Arneball/goasted/rules"
+	"github.com/Arneball/goasted/watch"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// runWatch implements "goasted watch": analyze, then re-analyze the
+// packages affected by every change until interrupted
+func runWatch(args []string) int {
+	var af analysisFlags
+	var interval time.Duration
+	var noClear bool
+
+	fs := flag.NewFlagSet("goasted watch", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: goasted watch [flags] [packages]")
+		fs.PrintDefaults()
+	}
+	af.register(fs)
+	fs.DurationVar(&interval, "interval", 500*time.Millisecond, "How often to check the analyzed tree for changes")
+	fs.BoolVar(&noClear, "no-clear", false, "Don't clear the screen before each report")
+	_ = fs.Parse(args)
+	af.patterns = fs.Args()
+
+	// Profiles would be overwritten by every run
+	if af.cpuProfile != "" || af.trace != "" {
+		_, _ = fmt.Fprintln(os.Stderr, "Error -cpuprofile and -trace are not supported in watch mode")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	roots, err := af.watchRoots()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	targetDir, targets, err := af.targets()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	watcher, err := watch.New(roots, interval)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error watching files: %v\n", err)
+		return 1
+	}
+	graph, err := watch.LoadGraph(roots)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error watching files: %v\n", err)
+		return 1
+	}
+
+	w := &watchReport{out: os.Stdout, clear: !noClear}
+	start := time.Now()
+	violations, _, err := af.analyze()
+	w.report(violations, nil, "all packages", time.Since(start), err)
+
+	for {
+		changed, err := watcher.Next(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return 0
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "Error watching files: %v\n", err)
+			return 1
+		}
+
+		next, err := watch.LoadGraph(roots)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error watching files: %v\n", err)
+			return 1
+		}
+
+		start := time.Now()
+		if watch.NeedsFullRun(changed) {
+			current, _, err := af.analyze()
+			w.report(current, changed, "all packages", time.Since(start), err)
+			if err == nil {
+				violations = current
+			}
+		} else {
+			// Packages removed since the last run only lose their violations.
+			// The watched trees may hold more packages than the targets.
+			affected := union(graph.Affected(changed), next.Affected(changed))
+			var dirs []string
+			for _, dir := range affected {
+				if next.Has(dir) && next.Matches(dir, targetDir, targets) {
+					dirs = append(dirs, dir)
+				}
+			}
+
+			var current []rules.Violation
+			if len(dirs) > 0 {
+				sub := af
+				sub.path = "."
+				sub.patterns = dirs
+				current, _, err = sub.analyze()
+			}
+			if err == nil {
+				violations = replaceDirs(violations, current, affected)
+			}
+			w.report(violations, changed, fmt.Sprintf("%d package(s)", len(dirs)), time.Since(start), err)
+		}
+		graph = next
+	}
+}
+
+// watchRoots returns the directories to watch: those of the directory
+// patterns, or -path. Import path patterns watch the current directory.
+func (af *analysisFlags) watchRoots() ([]string, error) {
+	targets := af.patterns
+	if len(targets) == 0 {
+		targets = []string{af.path}
+	}
+
+	seen := make(map[string]bool)
+	var roots []string
+	for _, target := range targets {
+		dir := configDir(strings.TrimSuffix(filepath.ToSlash(target), "/..."))
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			dir = "."
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			roots = append(roots, abs)
+		}
+	}
+	return roots, nil
+}
+
+// union merges sorted lists of directories
+func union(a, b []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, dir := range append(append([]string(nil), a...), b...) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// replaceDirs replaces the violations in files of the given directories with
+// those of a new run, keeping the order of violations
+func replaceDirs(violations, current []rules.Violation, dirs []string) []rules.Violation {
+	inDirs := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		inDirs[dir] = true
+	}
+	affected := func(v rules.Violation) bool {
+		abs, err := filepath.Abs(v.File)
+		return err == nil && inDirs[filepath.Dir(abs)]
+	}
+
+	var merged []rules.Violation
+	for _, v := range violations {
+		if !affected(v) {
+			merged = append(merged, v)
+		}
+	}
+	for _, v := range current {
+		if affected(v) {
+			merged = append(merged, v)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return merged
+}
+
+// watchReport prints the state after each run of watch mode, with the
+// violations gained and fixed since the previous run
+type watchReport struct {
+	out   io.Writer
+	clear bool
+
+	// previous holds the violations of the last successful run, if any
+	ran      bool
+	previous []rules.Violation
+}
+
+// report prints the violations of a run that analyzed what, triggered by
+// changed. A failed run is reported and the previous violations are kept.
+func (w *watchReport) report(violations []rules.Violation, changed []string, what string, took time.Duration, err error) {
+	if w.clear {
+		_, _ = fmt.Fprint(w.out, clearScreen)
+	}
+
+	_, _ = fmt.Fprintf(w.out, "[%s] analyzed %s in %s", time.Now().Format(time.TimeOnly), what, took.Round(time.Millisecond))
+	switch len(changed) {
+	case 0:
+	case 1:
+		_, _ = fmt.Fprintf(w.out, " after a change to %s", relative(changed[0]))
+	default:
+		_, _ = fmt.Fprintf(w.out, " after changes to %d files", len(changed))
+	}
+	_, _ = fmt.Fprint(w.out, "\n\n")
+
+	if err != nil {
+		_, _ = fmt.Fprintf(w.out, "Error %v\n\nWaiting for changes...\n", err)
+		return
+	}
+
+	_ = formatter.TextFormatter{}.Format(violations, w.out)
+
+	if w.ran {
+		gained, fixed := watch.Diff(w.previous, violations)
+		_, _ = fmt.Fprintln(w.out)
+		if len(gained) == 0 && len(fixed) == 0 {
+			_, _ = fmt.Fprintln(w.out, "No change since the previous run.")
+		}
+		if len(fixed) > 0 {
+			_, _ = fmt.Fprintf(w.out, "Fixed %d violation(s):\n", len(fixed))
+			for _, v := range fixed {
+				_, _ = fmt.Fprintf(w.out, "- %s\n", formatter.FormatLine(v))
+			}
+		}
+		if len(gained) > 0 {
+			_, _ = fmt.Fprintf(w.out, "New %d violation(s):\n", len(gained))
+			for _, v := range gained {
+				_, _ = fmt.Fprintf(w.out, "+ %s\n", formatter.FormatLine(v))
+			}
+		}
+	}
+	w.ran = true
+	w.previous = violations
+
+	_, _ = fmt.Fprintln(w.out, "\nWaiting for changes...")
+}
+
+// relative shortens path relative to the current directory, if it is below
+func relative(path string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}